@@ -0,0 +1,237 @@
+package dbauth
+
+import (
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/errorcode"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/signer"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/token"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	errorcodes "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+)
+
+// Client generates authentication tokens with its own options, token cache and background refreshes.
+type Client struct {
+	options model.ClientOptions
+	engine  *signer.Engine
+}
+
+// NewClient creates a new Client with the provided options. Nil options use the default values.
+func NewClient(options *model.ClientOptions) (*Client, error) {
+	if options == nil {
+		options = model.NewClientOptions()
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return newClient(*options), nil
+}
+
+func newClient(options model.ClientOptions) *Client {
+	return &Client{options: options, engine: signer.NewEngine(options)}
+}
+
+// GenerateAuthenticationToken generates an authentication token based on the provided token request.
+func (c *Client) GenerateAuthenticationToken(tokenRequest *model.GenerateAuthenticationTokenRequest) (string, error) {
+	authToken, _, err := c.getAuthToken(tokenRequest)
+	if err != nil {
+		return "", err
+	}
+	return authToken.GetAuthToken(), nil
+}
+
+// GenerateAuthenticationTokenLease generates an authentication token based on the provided token request and
+// returns it together with the time until which it is valid.
+func (c *Client) GenerateAuthenticationTokenLease(
+	tokenRequest *model.GenerateAuthenticationTokenRequest) (*model.AuthTokenLease, error) {
+	authToken, source, err := c.getAuthToken(tokenRequest)
+	if err != nil {
+		return nil, err
+	}
+	return newAuthTokenLease(authToken, authToken.GetExpiresAt(), source == model.TokenSourceStaleGrace), nil
+}
+
+// GenerateAuthenticationTokenPair generates an authentication token based on the provided token request and
+// returns it together with the token it replaced, if the replacement happened within the previous token overlap
+// and the replaced token has not expired yet.
+func (c *Client) GenerateAuthenticationTokenPair(
+	tokenRequest *model.GenerateAuthenticationTokenRequest) (*model.AuthTokenPair, error) {
+	authToken, source, err := c.getAuthToken(tokenRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	pair := &model.AuthTokenPair{
+		Current: newAuthTokenLease(authToken, authToken.GetExpiresAt(), source == model.TokenSourceStaleGrace),
+	}
+	previousToken, previousUntil := c.engine.New(*tokenRequest).GetPreviousAuthTokenFromCache()
+	if previousToken != nil && previousToken.GetAuthToken() != authToken.GetAuthToken() {
+		pair.Previous = newAuthTokenLease(previousToken, previousUntil, false)
+	}
+	return pair, nil
+}
+
+// GenerateAuthenticationTokenDetailed generates an authentication token based on the provided token request and
+// returns it together with its expiry, the source it was served from and the metadata embedded by CAM.
+func (c *Client) GenerateAuthenticationTokenDetailed(
+	tokenRequest *model.GenerateAuthenticationTokenRequest) (*model.AuthTokenResult, error) {
+	authToken, source, err := c.getAuthToken(tokenRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &model.AuthTokenResult{
+		Password: authToken.GetAuthToken(),
+		Expiry:   authToken.GetExpiresAt(),
+		Source:   source,
+		Fallback: authToken.IsFallback(),
+	}
+	if info := authToken.GetFallbackInfo(); info != nil {
+		result.FallbackSource = info.Source
+		result.FallbackMetadata = info.Metadata
+	}
+	if metadata := authToken.GetMetadata(); metadata != nil {
+		result.RequestId = metadata.RequestId
+		result.CamCurrentTime = millisToTime(metadata.CurrentTime)
+		result.NextRotationTime = millisToTime(metadata.NextRotationTime)
+		result.ClockSkew = metadata.ClockSkew
+		if info := metadata.TokenInfo; info != nil {
+			result.Info = &model.AuthTokenInfo{
+				AppId:      info.AppId,
+				Uin:        info.Uin,
+				OwnerUin:   info.OwnerUin,
+				CreateTime: info.CreateTime,
+				TokenType:  info.TokenType,
+				ExtraInfo:  info.ExtraInfo,
+			}
+		}
+	}
+	return result, nil
+}
+
+// Close stops the background work of the client: the background refreshes of its tokens and the watch of its
+// fallback provider. The fallback provider is closed if it implements io.Closer, such as a fallback.VaultProvider
+// or a fallback.Chain. The client can still generate tokens afterwards, but they are no longer refreshed in the
+// background.
+func (c *Client) Close() {
+	c.engine.Close()
+}
+
+// ClockSkew returns the latest estimate of the offset of the CAM clock from the local clock. It is positive when
+// the CAM clock is ahead, and zero until a token has been issued by CAM.
+func (c *Client) ClockSkew() time.Duration {
+	return c.engine.ClockSkew()
+}
+
+func newAuthTokenLease(authToken *token.Token, validUntil time.Time, stale bool) *model.AuthTokenLease {
+	return &model.AuthTokenLease{
+		AuthToken:  authToken.GetAuthToken(),
+		ValidUntil: validUntil,
+		Fallback:   authToken.IsFallback(),
+		Stale:      stale,
+	}
+}
+
+func millisToTime(millis int64) time.Time {
+	return time.Unix(0, millis*int64(time.Millisecond))
+}
+
+// getAuthToken gets the authentication token of the request and the source it is served from.
+func (c *Client) getAuthToken(
+	tokenRequest *model.GenerateAuthenticationTokenRequest) (*token.Token, model.TokenSource, error) {
+	if tokenRequest == nil {
+		return nil, 0, errors.NewTencentCloudSDKError(
+			errorcodes.INVALIDPARAMETER_PARAMERROR, "The token request is invalid.", "")
+	}
+	if tokenRequest.MinValidity() < 0 {
+		return nil, 0, errors.NewTencentCloudSDKError(
+			errorcodes.INVALIDPARAMETER_PARAMERROR, "The min validity is invalid.", "")
+	}
+	if mode, ok := tokenRequest.FallbackPolicy().(model.FallbackMode); ok {
+		switch mode {
+		case model.FallbackOnRetryableErrors, model.FallbackNever, model.FallbackAlways:
+		default:
+			return nil, 0, errors.NewTencentCloudSDKError(
+				errorcodes.INVALIDPARAMETER_PARAMERROR, "The fallback policy is invalid.", "")
+		}
+	}
+
+	minValidity := c.minValidity(tokenRequest)
+	// Create a new Signer with the provided token request.
+	s := c.engine.New(*tokenRequest)
+	policy := s.FallbackPolicy()
+	// Get the authentication token from the cache. A fallback token cached under another fallback policy, such as
+	// the one of the client, is not served unless the policy of the request allows it.
+	cachedToken := s.GetAuthTokenFromCache()
+	if cachedToken != nil && cachedToken.IsFallback() &&
+		!policy.AllowFallback(tokenRequest, cachedToken.GetFallbackInfo().Err) {
+		cachedToken = nil
+	}
+	if cachedToken != nil {
+		if cachedToken.IsValidFor(c.engine.Now(), clampMinValidity(minValidity, cachedToken)) &&
+			!s.IsFallbackRecheckDue(cachedToken) {
+			// If the token is valid for at least the min validity, return the token.
+			return cachedToken, cachedTokenSource(cachedToken), nil
+		}
+	}
+
+	err := s.BuildAuthToken()
+	if err == nil {
+		authToken := s.GetAuthTokenFromCache()
+		if !authToken.IsValidFor(c.engine.Now(), minValidity) {
+			logging.Warnf("The refreshed authentication token expires in less than the min validity of %v, "+
+				"it is served from the cache for half of its lifetime of %v", minValidity, authToken.Lifetime())
+		}
+		if authToken.IsFallback() {
+			return authToken, model.TokenSourceFallback, nil
+		}
+		return authToken, model.TokenSourceCam, nil
+	} else {
+		logging.Error("Error occurred while generating authentication token", err)
+		if cachedToken != nil {
+			if errorcode.IsUserNotificationRequired(err) {
+				// If the error code requires user notification, return the error.
+				return nil, 0, err
+			}
+			if cachedToken.IsFallback() && !policy.AllowFallback(tokenRequest, err) {
+				// If the fallback policy does not allow the fallback after the error, return the error.
+				return nil, 0, err
+			}
+			// If the error code does not require user notification, return the cached token.
+			if cachedToken.IsValidFor(c.engine.Now(), 0) {
+				return cachedToken, cachedTokenSource(cachedToken), nil
+			}
+			return cachedToken, model.TokenSourceStaleGrace, nil
+		}
+		// If there is no cached token, return the error.
+		return nil, 0, err
+	}
+}
+
+// clampMinValidity returns the min validity of the cached token, clamped to half of its lifetime. A longer min
+// validity could not be met by the tokens CAM issues before their next rotation either, so that every request would
+// refresh the token instead of serving it from the cache.
+func clampMinValidity(minValidity time.Duration, cachedToken *token.Token) time.Duration {
+	if lifetime := cachedToken.Lifetime(); lifetime > 0 && minValidity > lifetime/2 {
+		return lifetime / 2
+	}
+	return minValidity
+}
+
+// cachedTokenSource returns the source of a token served from the cache before it expires.
+func cachedTokenSource(cachedToken *token.Token) model.TokenSource {
+	if cachedToken.IsFallback() {
+		return model.TokenSourceFallback
+	}
+	return model.TokenSourceCache
+}
+
+// minValidity returns the min validity of the request, or of the client if the request has none.
+func (c *Client) minValidity(tokenRequest *model.GenerateAuthenticationTokenRequest) time.Duration {
+	minValidity := tokenRequest.MinValidity()
+	if minValidity == 0 {
+		minValidity = c.options.MinValidity
+	}
+	return minValidity
+}
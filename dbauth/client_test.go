@@ -0,0 +1,1190 @@
+package dbauth_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/fallback"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	cam "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
+)
+
+func newTestRequest(t *testing.T, server *dbauthtest.CamServer) *model.GenerateAuthenticationTokenRequest {
+	request, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-123456", "camtest",
+		common.NewCredential("secretId", "secretKey"), server.ClientProfile())
+	assert.NoError(t, err)
+	return request
+}
+
+func newTestClient(t *testing.T, fakeClock *dbauthtest.FakeClock,
+	configure func(options *model.ClientOptions)) *dbauth.Client {
+	options := model.NewClientOptions()
+	options.Clock = fakeClock
+	if configure != nil {
+		configure(options)
+	}
+	client, err := dbauth.NewClient(options)
+	assert.NoError(t, err)
+	return client
+}
+
+// testProxy is a forward HTTP proxy which records the requests passing through it.
+type testProxy struct {
+	*httptest.Server
+
+	mu                 sync.Mutex
+	requestCount       int
+	proxyAuthorization string
+}
+
+func newTestProxy() *testProxy {
+	proxy := &testProxy{}
+	forward := &httputil.ReverseProxy{Director: func(*http.Request) {}}
+	proxy.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxy.mu.Lock()
+		proxy.requestCount++
+		proxy.proxyAuthorization = r.Header.Get("Proxy-Authorization")
+		proxy.mu.Unlock()
+		forward.ServeHTTP(w, r)
+	}))
+	return proxy
+}
+
+func (p *testProxy) RequestCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requestCount
+}
+
+func (p *testProxy) ProxyAuthorization() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.proxyAuthorization
+}
+
+func TestNewClient_InvalidOptions(t *testing.T) {
+	options := model.NewClientOptions()
+	options.RefreshMode = model.RefreshMode(42)
+
+	_, err := dbauth.NewClient(options)
+	assert.Error(t, err)
+}
+
+func TestGenerateAuthenticationToken_ServedFromCache(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), nil)
+
+	first, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	second, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "fake-password-1", first)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, server.RequestCount())
+}
+
+func TestGenerateAuthenticationToken_ProactiveRefresh(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, nil)
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fakeClock.PendingTimers())
+
+	// The 15 minutes token is refreshed after about three quarters of its lifetime.
+	fakeClock.Advance(12 * time.Minute)
+	assert.Equal(t, 2, server.RequestCount())
+
+	authToken, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-2", authToken)
+	assert.Equal(t, 2, server.RequestCount())
+}
+
+func TestGenerateAuthenticationToken_LazyRefresh(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+	})
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+
+	fakeClock.Advance(14 * time.Minute)
+	authToken, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", authToken)
+
+	fakeClock.Advance(time.Minute)
+	authToken, err = client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-2", authToken)
+	assert.Equal(t, 2, server.RequestCount())
+}
+
+func TestGenerateAuthenticationToken_HybridRefreshesHotKeysOnly(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeHybrid
+	})
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+
+	hotRequest, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-654321", "camtest",
+		common.NewCredential("secretId", "secretKey"), server.ClientProfile())
+	assert.NoError(t, err)
+	hotRequest.SetHot(true)
+	_, err = client.GenerateAuthenticationToken(hotRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fakeClock.PendingTimers())
+}
+
+func TestGenerateAuthenticationTokenLease_MinValidity(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.MinValidity = time.Minute
+	})
+
+	lease, err := client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, fakeClock.Now().Add(15*time.Minute), lease.ValidUntil)
+
+	// With less than the min validity left, the token is refreshed synchronously.
+	fakeClock.Advance(14*time.Minute + 30*time.Second)
+	lease, err = client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-2", lease.AuthToken)
+
+	// A per-request min validity overrides the client one.
+	fakeClock.Advance(9 * time.Minute)
+	request := newTestRequest(t, server)
+	request.SetMinValidity(7 * time.Minute)
+	lease, err = client.GenerateAuthenticationTokenLease(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-3", lease.AuthToken)
+}
+
+func TestGenerateAuthenticationTokenLease_StaleGrace(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+	})
+
+	lease, err := client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.False(t, lease.Stale)
+
+	// The expired token served while CAM fails is flagged as stale, its validity is in the past.
+	server.InjectThrottling(-1)
+	fakeClock.Advance(16 * time.Minute)
+	lease, err = client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", lease.AuthToken)
+	assert.True(t, lease.Stale)
+	assert.True(t, lease.ValidUntil.Before(fakeClock.Now()))
+}
+
+func TestGenerateAuthenticationTokenLease_MinValidityClampedToHalfLifetime(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.MinValidity = 20 * time.Minute
+	})
+
+	first, err := client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+
+	// No token lives for the min validity, so the token is served from the cache for half of its lifetime.
+	fakeClock.Advance(7 * time.Minute)
+	second, err := client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, server.RequestCount())
+
+	fakeClock.Advance(time.Minute)
+	third, err := client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-2", third.AuthToken)
+	assert.Equal(t, 2, server.RequestCount())
+}
+
+func TestGenerateAuthenticationTokenLease_ServedWithinMinValidity(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.MinValidity = time.Minute
+	})
+
+	first, err := client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+
+	// With more than the min validity left, the cached token and its validity are returned.
+	fakeClock.Advance(13 * time.Minute)
+	second, err := client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, server.RequestCount())
+}
+
+func TestGenerateAuthenticationTokenLease_InvalidMinValidity(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), nil)
+
+	request := newTestRequest(t, server)
+	request.SetMinValidity(-time.Second)
+	_, err := client.GenerateAuthenticationTokenLease(request)
+	assert.Error(t, err)
+	assert.Equal(t, 0, server.RequestCount())
+
+	options := model.NewClientOptions()
+	options.MinValidity = -time.Second
+	_, err = dbauth.NewClient(options)
+	assert.Error(t, err)
+}
+
+func TestGenerateAuthenticationTokenPair_PreviousTokenOverlap(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.MinValidity = 5 * time.Minute
+		options.PreviousTokenOverlap = 2 * time.Minute
+	})
+
+	pair, err := client.GenerateAuthenticationTokenPair(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Nil(t, pair.Previous)
+
+	fakeClock.Advance(12 * time.Minute)
+	pair, err = client.GenerateAuthenticationTokenPair(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-2", pair.Current.AuthToken)
+	assert.Equal(t, "fake-password-1", pair.Previous.AuthToken)
+	assert.Equal(t, fakeClock.Now().Add(2*time.Minute), pair.Previous.ValidUntil)
+
+	fakeClock.Advance(2 * time.Minute)
+	pair, err = client.GenerateAuthenticationTokenPair(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Nil(t, pair.Previous)
+}
+
+func TestGenerateAuthenticationTokenPair_PreviousTokenExpiresWithinOverlap(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.MinValidity = 5 * time.Minute
+		options.PreviousTokenOverlap = 10 * time.Minute
+	})
+
+	pair, err := client.GenerateAuthenticationTokenPair(newTestRequest(t, server))
+	assert.NoError(t, err)
+	previousExpiry := pair.Current.ValidUntil
+
+	// The previous token is only valid until it expires, before the end of the overlap period.
+	fakeClock.Advance(12 * time.Minute)
+	pair, err = client.GenerateAuthenticationTokenPair(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-2", pair.Current.AuthToken)
+	assert.Equal(t, "fake-password-1", pair.Previous.AuthToken)
+	assert.Equal(t, previousExpiry, pair.Previous.ValidUntil)
+
+	fakeClock.Advance(3 * time.Minute)
+	pair, err = client.GenerateAuthenticationTokenPair(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-2", pair.Current.AuthToken)
+	assert.Nil(t, pair.Previous)
+}
+
+func TestGenerateAuthenticationTokenDetailed_Sources(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+	})
+
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceCam, result.Source)
+	assert.Equal(t, "fake-request-1", result.RequestId)
+	assert.Equal(t, fakeClock.Now().Add(15*time.Minute).UnixNano()/int64(time.Millisecond),
+		result.NextRotationTime.UnixNano()/int64(time.Millisecond))
+	assert.NotNil(t, result.Info)
+
+	result, err = client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceCache, result.Source)
+
+	// An expired token is served while CAM fails with an error which requires no user notification.
+	server.InjectThrottling(-1)
+	fakeClock.Advance(16 * time.Minute)
+	result, err = client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceStaleGrace, result.Source)
+	assert.Equal(t, "fake-password-1", result.Password)
+}
+
+func TestGenerateAuthenticationTokenDetailed_CamMetadata(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, nil)
+
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", result.Password)
+	assert.Equal(t, fakeClock.Now().Add(15*time.Minute), result.Expiry)
+	nowMillis := fakeClock.Now().UnixNano() / int64(time.Millisecond)
+	assert.Equal(t, nowMillis, result.CamCurrentTime.UnixNano()/int64(time.Millisecond))
+	assert.Equal(t, uint64(nowMillis), result.Info.CreateTime)
+	assert.False(t, result.Fallback)
+	assert.Empty(t, result.FallbackSource)
+	assert.Equal(t, "CAM", result.Source.String())
+}
+
+func TestGenerateAuthenticationTokenDetailed_FallbackHasNoCamMetadata(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+		options.FallbackProvider = fallback.Func(
+			func(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+				return &model.FallbackPassword{Value: "fallback"}, nil
+			})
+	})
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", result.Password)
+	assert.Equal(t, model.TokenSourceFallback, result.Source)
+	assert.True(t, result.Fallback)
+	assert.Nil(t, result.Info)
+	assert.Empty(t, result.RequestId)
+	assert.True(t, result.NextRotationTime.IsZero())
+	assert.True(t, result.CamCurrentTime.IsZero())
+	assert.Equal(t, "Fallback", result.Source.String())
+}
+
+func TestGenerateAuthenticationToken_UserNotificationError(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	server.InjectDataFlowAuthClose(1)
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), nil)
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.Error(t, err)
+	assert.Equal(t, 1, server.RequestCount())
+}
+
+func TestGenerateAuthenticationToken_FaultTransport(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	transport := dbauthtest.NewFaultTransport(nil, 7)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.Transport = transport
+	})
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+
+	// Every attempt of the refresh fails, so the expired token is served under stale grace.
+	transport.Schedule(dbauthtest.FaultConnectionReset, dbauthtest.FaultServerError, dbauthtest.FaultMalformedToken)
+	fakeClock.Advance(16 * time.Minute)
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceStaleGrace, result.Source)
+	assert.Equal(t, 1, transport.Count(dbauthtest.FaultMalformedToken))
+
+	result, err = client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceCam, result.Source)
+}
+
+func TestNewClient_InvalidProxyOptions(t *testing.T) {
+	for _, configure := range []func(options *model.ClientOptions){
+		func(options *model.ClientOptions) { options.ProxyURL = "proxy.example.com:3128" },
+		func(options *model.ClientOptions) { options.ProxyURL = "ftp://proxy.example.com" },
+		func(options *model.ClientOptions) {
+			options.ProxyURL = "http://proxy.example.com:3128"
+			options.Transport = dbauthtest.NewFaultTransport(nil, 1)
+		},
+	} {
+		options := model.NewClientOptions()
+		configure(options)
+
+		_, err := dbauth.NewClient(options)
+		assert.Error(t, err)
+	}
+}
+
+func TestGenerateAuthenticationToken_ProxyURL(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	proxy := newTestProxy()
+	defer proxy.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.ProxyURL = "http://user:secret@" + proxy.Listener.Addr().String()
+	})
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, proxy.RequestCount())
+	assert.Equal(t, "Basic dXNlcjpzZWNyZXQ=", proxy.ProxyAuthorization())
+
+	// The background refresh goes through the proxy as well.
+	fakeClock.Advance(12 * time.Minute)
+	assert.Equal(t, 2, server.RequestCount())
+	assert.Equal(t, 2, proxy.RequestCount())
+}
+
+func TestGenerateAuthenticationToken_RootCAs(t *testing.T) {
+	server := dbauthtest.NewTLSCamServer()
+	defer server.Close()
+
+	untrusted := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), nil)
+	_, err := untrusted.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.Error(t, err)
+	assert.Equal(t, 0, server.RequestCount())
+
+	trusted := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+		options.RootCAs = server.RootCAs()
+	})
+	authToken, err := trusted.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", authToken)
+}
+
+func TestGenerateAuthenticationToken_ReusesConnections(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, nil)
+
+	for _, secretId := range []string{"secretId", "secretId2", "secretId3"} {
+		request, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-123456", "camtest",
+			common.NewCredential(secretId, "secretKey"), server.ClientProfile())
+		assert.NoError(t, err)
+		_, err = client.GenerateAuthenticationToken(request)
+		assert.NoError(t, err)
+	}
+	fakeClock.Advance(12 * time.Minute)
+
+	// The clients of every secret id and their refreshes share one keep-alive connection.
+	assert.Equal(t, 6, server.RequestCount())
+	assert.Equal(t, 1, server.ConnectionCount())
+}
+
+func TestGenerateAuthenticationToken_EndpointFailover(t *testing.T) {
+	primary := dbauthtest.NewCamServer()
+	defer primary.Close()
+	secondary := dbauthtest.NewCamServer()
+	defer secondary.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.Endpoints = []string{primary.Endpoint(), secondary.Endpoint()}
+		options.EndpointCooldown = time.Minute
+	})
+	newRequest := func(userName string) *model.GenerateAuthenticationTokenRequest {
+		request, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-123456", userName,
+			common.NewCredential("secretId", "secretKey"), primary.ClientProfile())
+		assert.NoError(t, err)
+		return request
+	}
+
+	primary.SetUnreachable(true)
+	_, err := client.GenerateAuthenticationToken(newRequest("camtest"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, primary.RequestCount())
+	assert.Equal(t, 1, secondary.RequestCount())
+
+	// The primary endpoint is skipped until the cooldown ends, even once it has recovered.
+	primary.SetUnreachable(false)
+	fakeClock.Advance(30 * time.Second)
+	_, err = client.GenerateAuthenticationToken(newRequest("camtest2"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, primary.RequestCount())
+	assert.Equal(t, 2, secondary.RequestCount())
+
+	fakeClock.Advance(30 * time.Second)
+	_, err = client.GenerateAuthenticationToken(newRequest("camtest3"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, primary.RequestCount())
+	assert.Equal(t, 2, secondary.RequestCount())
+}
+
+func TestNewClient_InvalidEndpoints(t *testing.T) {
+	for _, configure := range []func(options *model.ClientOptions){
+		func(options *model.ClientOptions) { options.Endpoints = []string{""} },
+		func(options *model.ClientOptions) { options.Endpoints = []string{"https://cam.tencentcloudapi.com"} },
+		func(options *model.ClientOptions) { options.EndpointCooldown = -time.Second },
+	} {
+		options := model.NewClientOptions()
+		configure(options)
+
+		_, err := dbauth.NewClient(options)
+		assert.Error(t, err)
+	}
+}
+
+func TestGenerateAuthenticationToken_RateLimit(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	metrics := dbauthtest.NewMetricsRecorder()
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.RateLimit = 1
+		options.Metrics = metrics
+	})
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+
+	// The request of another key waits for the rate limiter.
+	request, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-123456", "camtest2",
+		common.NewCredential("secretId", "secretKey"), server.ClientProfile())
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		_, err := client.GenerateAuthenticationToken(request)
+		done <- err
+	}()
+	for fakeClock.PendingTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 1, server.RequestCount())
+
+	fakeClock.Advance(time.Second)
+	assert.NoError(t, <-done)
+	assert.Equal(t, 2, server.RequestCount())
+	assert.Equal(t, []time.Duration{0, time.Second}, metrics.Durations(model.MetricCamQueueDelay, nil))
+}
+
+func TestGenerateAuthenticationToken_RateLimitAdaptsToThrottling(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	metrics := dbauthtest.NewMetricsRecorder()
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+		options.RateLimit = 32
+		options.RateLimitBurst = 8
+		options.Metrics = metrics
+	})
+	server.InjectThrottling(1)
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+
+	// The rate is halved by the throttled attempt and grows back by the successful one.
+	assert.Equal(t, 1, metrics.Counter(model.MetricCamThrottled, nil))
+	rate, ok := metrics.Gauge(model.MetricCamRateLimit, nil)
+	assert.True(t, ok)
+	assert.Equal(t, 17.0, rate)
+}
+
+func TestGenerateAuthenticationToken_MaxConcurrentRequests(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	server.SetLatency(20 * time.Millisecond)
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.MaxConcurrentRequests = 1
+	})
+
+	var wg sync.WaitGroup
+	for _, userName := range []string{"camtest", "camtest2", "camtest3"} {
+		request, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-123456", userName,
+			common.NewCredential("secretId", "secretKey"), server.ClientProfile())
+		assert.NoError(t, err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := client.GenerateAuthenticationToken(request)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 3, server.RequestCount())
+	assert.Equal(t, 1, server.MaxInFlight())
+}
+
+func TestNewClient_InvalidRateLimit(t *testing.T) {
+	for _, configure := range []func(options *model.ClientOptions){
+		func(options *model.ClientOptions) { options.RateLimit = -1 },
+		func(options *model.ClientOptions) { options.RateLimitBurst = -1 },
+		func(options *model.ClientOptions) { options.MaxConcurrentRequests = -1 },
+	} {
+		options := model.NewClientOptions()
+		configure(options)
+
+		_, err := dbauth.NewClient(options)
+		assert.Error(t, err)
+	}
+}
+
+func TestGenerateAuthenticationToken_CircuitBreaker(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	transport := dbauthtest.NewFaultTransport(nil, 1)
+	metrics := dbauthtest.NewMetricsRecorder()
+	var mu sync.Mutex
+	var events []model.Event
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.Transport = transport
+		options.CircuitBreakerThreshold = 2
+		options.CircuitBreakerOpenDuration = time.Minute
+		options.Metrics = metrics
+		options.EventListener = model.EventListenerFunc(func(event model.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+		})
+	})
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+
+	// The second failed attempt opens the circuit, so the third one is not sent.
+	transport.SetRate(dbauthtest.FaultConnectionReset, 1)
+	fakeClock.Advance(16 * time.Minute)
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceStaleGrace, result.Source)
+	assert.Equal(t, 2, transport.Count(dbauthtest.FaultConnectionReset))
+
+	// While the circuit is open, no request is sent.
+	result, err = client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceStaleGrace, result.Source)
+	assert.Equal(t, 2, transport.Count(dbauthtest.FaultConnectionReset))
+
+	// After the open duration, the probe request closes the circuit.
+	transport.SetRate(dbauthtest.FaultConnectionReset, 0)
+	fakeClock.Advance(time.Minute)
+	result, err = client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceCam, result.Source)
+
+	mu.Lock()
+	defer mu.Unlock()
+	endpoint := server.Endpoint()
+	assert.Len(t, events, 3)
+	for i, state := range []model.CircuitState{model.CircuitOpen, model.CircuitHalfOpen, model.CircuitClosed} {
+		assert.Equal(t, model.EventCircuitStateChanged, events[i].Type)
+		assert.Equal(t, endpoint, events[i].Endpoint)
+		assert.Equal(t, state, events[i].CircuitState)
+	}
+	assert.Equal(t, model.CircuitClosed, events[0].PreviousCircuitState)
+
+	state, ok := metrics.Gauge(model.MetricCamCircuitState, map[string]string{"endpoint": endpoint})
+	assert.True(t, ok)
+	assert.Equal(t, float64(model.CircuitClosed), state)
+	assert.Equal(t, 1, metrics.Counter(model.MetricCamCircuitTransitions,
+		map[string]string{"endpoint": endpoint, "state": "Open"}))
+}
+
+func TestGenerateAuthenticationToken_FallbackProvider(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	expiry := fakeClock.Now().Add(time.Hour)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.FallbackProvider = fallback.NewChain(
+			fallback.NewDirectoryProvider(t.TempDir()),
+			fallback.Func(func(request *model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+				return &model.FallbackPassword{Value: "fallback-" + request.UserName(), Expiry: expiry}, nil
+			}),
+		)
+	})
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback-camtest", result.Password)
+	assert.Equal(t, model.TokenSourceFallback, result.Source)
+	assert.Equal(t, expiry, result.Expiry)
+}
+
+func TestGenerateAuthenticationToken_FallbackTTL(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.FallbackTTL = 10 * time.Minute
+		options.FallbackProvider = fallback.Func(
+			func(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+				return &model.FallbackPassword{Value: "fallback", Expiry: fakeClock.Now().Add(time.Hour),
+					Source: "test", Metadata: map[string]string{"rotatedBy": "ops"}}, nil
+			})
+	})
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", result.Password)
+	assert.True(t, result.Fallback)
+	assert.Equal(t, "test", result.FallbackSource)
+	assert.Equal(t, map[string]string{"rotatedBy": "ops"}, result.FallbackMetadata)
+	assert.Equal(t, fakeClock.Now().Add(10*time.Minute), result.Expiry)
+
+	lease, err := client.GenerateAuthenticationTokenLease(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.True(t, lease.Fallback)
+}
+
+func TestNewClient_InvalidFallbackSettings(t *testing.T) {
+	for _, configure := range []func(options *model.ClientOptions){
+		func(options *model.ClientOptions) { options.FallbackTTL = -time.Second },
+		func(options *model.ClientOptions) { options.FallbackRecheckInterval = -time.Second },
+		func(options *model.ClientOptions) { options.FallbackPollInterval = -time.Second },
+	} {
+		options := model.NewClientOptions()
+		configure(options)
+
+		_, err := dbauth.NewClient(options)
+		assert.Error(t, err)
+	}
+}
+
+func TestGenerateAuthenticationToken_FallbackRecheckOnRequest(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.FallbackRecheckInterval = 10 * time.Second
+		options.FallbackProvider = fallback.Func(
+			func(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+				return &model.FallbackPassword{Value: "fallback"}, nil
+			})
+	})
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, model.TokenSourceFallback, result.Source)
+	requestCount := server.RequestCount()
+
+	// The fallback token is served from the cache until the recheck interval has passed.
+	server.ClearErrors()
+	fakeClock.Advance(9 * time.Second)
+	result, err = client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", result.Password)
+	assert.Equal(t, requestCount, server.RequestCount())
+
+	fakeClock.Advance(time.Second)
+	result, err = client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", result.Password)
+	assert.Equal(t, model.TokenSourceCam, result.Source)
+	assert.False(t, result.Fallback)
+	assert.Empty(t, result.FallbackSource)
+}
+
+func TestGenerateAuthenticationToken_FallbackRecheckInBackground(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.FallbackRecheckInterval = 2 * time.Second
+		options.FallbackProvider = fallback.Func(
+			func(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+				return &model.FallbackPassword{Value: "fallback"}, nil
+			})
+	})
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+
+	authToken, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", authToken)
+
+	// The background refresh replaces the fallback token as soon as CAM recovers.
+	server.ClearErrors()
+	fakeClock.Advance(2 * time.Second)
+	requestCount := server.RequestCount()
+	result, err := client.GenerateAuthenticationTokenDetailed(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", result.Password)
+	assert.Equal(t, model.TokenSourceCache, result.Source)
+	assert.Equal(t, requestCount, server.RequestCount())
+}
+
+func TestGenerateAuthenticationToken_RetryIgnoresFallbackRecheckInterval(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	server.SetTokenLifetime(4 * time.Second)
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.FallbackRecheckInterval = time.Second
+	})
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+
+	// A failed refresh of a CAM token is retried after the retry interval, not the fallback recheck interval.
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+	fakeClock.Advance(4 * time.Second)
+	requestCount := server.RequestCount()
+	server.ClearErrors()
+
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, requestCount, server.RequestCount())
+
+	fakeClock.Advance(4 * time.Second)
+	assert.Equal(t, requestCount+1, server.RequestCount())
+	authToken, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-2", authToken)
+}
+
+func TestGenerateAuthenticationToken_TokenStoreSurvivesRestart(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	server.SetClock(fakeClock)
+	key, err := fallback.GenerateKey()
+	assert.NoError(t, err)
+	dir := t.TempDir()
+	configure := func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.TokenStoreDir = dir
+		options.TokenStoreKey = key
+		options.FallbackProvider = fallback.NewChain()
+	}
+
+	authToken, err := newTestClient(t, fakeClock, configure).GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", authToken)
+
+	// A new client restores the token persisted by the first one while CAM is down.
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+	requestCount := server.RequestCount()
+	fakeClock.Advance(10 * time.Minute)
+	result, err := newTestClient(t, fakeClock, configure).GenerateAuthenticationTokenDetailed(
+		newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", result.Password)
+	assert.Equal(t, model.TokenSourceCache, result.Source)
+	assert.NotEmpty(t, result.RequestId)
+	assert.Equal(t, requestCount, server.RequestCount())
+
+	// The expired token is not restored.
+	fakeClock.Advance(5 * time.Minute)
+	_, err = newTestClient(t, fakeClock, configure).GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.Error(t, err)
+}
+
+func TestNewClient_InvalidTokenStoreKey(t *testing.T) {
+	options := model.NewClientOptions()
+	options.TokenStoreDir = t.TempDir()
+	options.TokenStoreKey = []byte("short")
+
+	_, err := dbauth.NewClient(options)
+	assert.Error(t, err)
+}
+
+func TestGenerateAuthenticationToken_FallbackHotReload(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ap-guangzhou_cdb-123456_camtest.pwd")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("first\n"), 0600))
+	var events []model.Event
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.FallbackRecheckInterval = time.Hour
+		options.FallbackPollInterval = time.Second
+		options.FallbackProvider = fallback.NewDirectoryProvider(dir)
+		options.EventListener = model.EventListenerFunc(func(event model.Event) {
+			events = append(events, event)
+		})
+	})
+	defer client.Close()
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+
+	authToken, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "first", authToken)
+
+	// The rotated password replaces the cached fallback token as soon as the change is polled.
+	assert.NoError(t, ioutil.WriteFile(path, []byte("second\n"), 0600))
+	fakeClock.Advance(time.Second)
+	assert.Len(t, events, 1)
+	assert.Equal(t, model.EventFallbackChanged, events[0].Type)
+	assert.Equal(t, path, events[0].Source)
+
+	authToken, err = client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "second", authToken)
+
+	client.Close()
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+}
+
+func TestGenerateAuthenticationToken_FallbackHotReloadKeepsOtherFiles(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	dir := t.TempDir()
+	firstPath := filepath.Join(dir, "ap-guangzhou_cdb-123456_camtest.pwd")
+	secondPath := filepath.Join(dir, "ap-guangzhou_cdb-123456_other.pwd")
+	assert.NoError(t, ioutil.WriteFile(firstPath, []byte("first\n"), 0600))
+	assert.NoError(t, ioutil.WriteFile(secondPath, []byte("other1\n"), 0600))
+	client := newTestClient(t, fakeClock, func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.FallbackRecheckInterval = time.Hour
+		options.FallbackPollInterval = time.Second
+		options.FallbackProvider = fallback.NewDirectoryProvider(dir)
+	})
+	defer client.Close()
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+	otherRequest, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-123456", "other",
+		common.NewCredential("secretId", "secretKey"), server.ClientProfile())
+	assert.NoError(t, err)
+
+	_, err = client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	authToken, err := client.GenerateAuthenticationToken(otherRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "other1", authToken)
+
+	// The second file is rewritten unnoticed by the poll, so it is only read again if its token is dropped.
+	secondInfo, err := os.Stat(secondPath)
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(secondPath, []byte("other2\n"), 0600))
+	assert.NoError(t, os.Chtimes(secondPath, secondInfo.ModTime(), secondInfo.ModTime()))
+	assert.NoError(t, ioutil.WriteFile(firstPath, []byte("second\n"), 0600))
+	fakeClock.Advance(time.Second)
+
+	authToken, err = client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "second", authToken)
+	authToken, err = client.GenerateAuthenticationToken(otherRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "other1", authToken)
+}
+
+// closingProvider is a FallbackProvider which provides no password and records whether it was closed.
+type closingProvider struct {
+	closed bool
+}
+
+func (p *closingProvider) FallbackPassword(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+	return nil, nil
+}
+
+func (p *closingProvider) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestClientClose_ClosesFallbackProviders(t *testing.T) {
+	provider := &closingProvider{}
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+		options.FallbackProvider = fallback.NewChain(fallback.NewEnvProvider(""), fallback.NewChain(provider))
+	})
+
+	client.Close()
+	assert.True(t, provider.closed)
+}
+
+func TestGenerateAuthenticationToken_FallbackPolicy(t *testing.T) {
+	passwordProvider := fallback.Func(func(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+		return &model.FallbackPassword{Value: "fallback"}, nil
+	})
+	for _, test := range []struct {
+		policy        model.FallbackPolicy
+		inject        func(server *dbauthtest.CamServer)
+		allowFallback bool
+	}{
+		{nil, func(server *dbauthtest.CamServer) { server.InjectError("InternalError", "Unavailable.", -1) }, true},
+		{nil, func(server *dbauthtest.CamServer) { server.InjectDataFlowAuthClose(-1) }, false},
+		{model.FallbackNever, func(server *dbauthtest.CamServer) {
+			server.InjectError("InternalError", "Unavailable.", -1)
+		}, false},
+		{model.FallbackAlways, func(server *dbauthtest.CamServer) { server.InjectDataFlowAuthClose(-1) }, true},
+		{model.FallbackAlways, func(server *dbauthtest.CamServer) { server.InjectAuthFailure(-1) }, true},
+	} {
+		server := dbauthtest.NewCamServer()
+		client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+			options.RefreshMode = model.RefreshModeLazy
+			options.FallbackPolicy = test.policy
+			options.FallbackProvider = passwordProvider
+		})
+		test.inject(server)
+
+		authToken, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+		if test.allowFallback {
+			assert.NoError(t, err, "%v", test.policy)
+			assert.Equal(t, "fallback", authToken)
+		} else {
+			assert.Error(t, err, "%v", test.policy)
+		}
+		server.Close()
+	}
+}
+
+func TestGenerateAuthenticationToken_RequestFallbackPolicy(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.FallbackPolicy = model.FallbackNever
+		options.FallbackProvider = fallback.Func(
+			func(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+				return &model.FallbackPassword{Value: "fallback"}, nil
+			})
+	})
+	server.InjectDataFlowAuthClose(-1)
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.Error(t, err)
+
+	// The policy of the request overrides the one of the client.
+	var policyErr error
+	request := newTestRequest(t, server)
+	request.SetFallbackPolicy(model.FallbackPolicyFunc(
+		func(request *model.GenerateAuthenticationTokenRequest, err error) bool {
+			policyErr = err
+			return request.UserName() == "camtest"
+		}))
+	authToken, err := client.GenerateAuthenticationToken(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", authToken)
+	assert.Contains(t, policyErr.Error(), dbauthtest.ErrorCodeDataFlowAuthClose)
+}
+
+func TestGenerateAuthenticationToken_RequestFallbackPolicySkipsCachedFallbackToken(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+		options.RefreshMode = model.RefreshModeLazy
+		options.FallbackProvider = fallback.Func(
+			func(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+				return &model.FallbackPassword{Value: "fallback"}, nil
+			})
+	})
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+
+	authToken, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", authToken)
+	requestCount := server.RequestCount()
+
+	// The cached fallback token is not served to a request which never allows the fallback, CAM is asked instead.
+	request := newTestRequest(t, server)
+	request.SetFallbackPolicy(model.FallbackNever)
+	authToken, err = client.GenerateAuthenticationToken(request)
+	assert.Error(t, err)
+	assert.Empty(t, authToken)
+	assert.Greater(t, server.RequestCount(), requestCount)
+
+	// The requests under the policy of the client are still served the cached fallback token.
+	authToken, err = client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, "fallback", authToken)
+
+	server.ClearErrors()
+	authToken, err = client.GenerateAuthenticationToken(request)
+	assert.NoError(t, err)
+	assert.Equal(t, "fake-password-1", authToken)
+}
+
+func TestGenerateAuthenticationToken_InvalidRequestFallbackPolicy(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), nil)
+
+	request := newTestRequest(t, server)
+	request.SetFallbackPolicy(model.FallbackMode(42))
+	_, err := client.GenerateAuthenticationToken(request)
+
+	sdkErr, ok := err.(*errors.TencentCloudSDKError)
+	assert.True(t, ok)
+	assert.Equal(t, cam.INVALIDPARAMETER_PARAMERROR, sdkErr.GetCode())
+	assert.Equal(t, "The fallback policy is invalid.", sdkErr.GetMessage())
+	assert.Equal(t, 0, server.RequestCount())
+}
+
+func TestNewClient_InvalidFallbackPolicy(t *testing.T) {
+	options := model.NewClientOptions()
+	options.FallbackPolicy = model.FallbackMode(42)
+
+	_, err := dbauth.NewClient(options)
+	assert.Error(t, err)
+}
+
+func TestGenerateAuthenticationToken_FallbackDisabled(t *testing.T) {
+	server := dbauthtest.NewCamServer()
+	defer server.Close()
+	client := newTestClient(t, dbauthtest.NewFakeClock(time.Now()), func(options *model.ClientOptions) {
+		options.FallbackProvider = fallback.NewChain()
+	})
+	server.InjectError("InternalError", "The service is unavailable.", -1)
+
+	_, err := client.GenerateAuthenticationToken(newTestRequest(t, server))
+	assert.Error(t, err)
+}
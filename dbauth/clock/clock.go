@@ -0,0 +1,34 @@
+// Package clock provides the time source of the dbauth package, so that it can be replaced in tests.
+package clock
+
+import "time"
+
+// Clock tells the current time and runs functions after a delay.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// AfterFunc waits for the duration to elapse and then calls f in its own goroutine.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer represents a function scheduled by a Clock.
+type Timer interface {
+	// Stop prevents the function from being called. It returns false if the function has already been called
+	// or the timer has been stopped.
+	Stop() bool
+}
+
+// System is the Clock backed by the time package. Its times carry monotonic clock readings.
+var System Clock = systemClock{}
+
+type systemClock struct{}
+
+// Now returns time.Now.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc calls time.AfterFunc.
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
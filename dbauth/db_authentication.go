@@ -2,49 +2,47 @@
 package dbauth
 
 import (
+	"time"
+
 	"github.com/sirupsen/logrus"
-	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/errorcode"
-	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/signer"
-	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/utils"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
-	errorcodes "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
-	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
 )
 
-var logging = logrus.WithField("component", "dbauth")
+var (
+	logging = logrus.WithField("component", "dbauth")
+	// defaultClient serves the package-level functions with the default options.
+	defaultClient = newClient(*model.NewClientOptions())
+)
 
 // GenerateAuthenticationToken generates an authentication token based on the provided token request.
+// It uses a client with the default options, see NewClient to configure them.
 func GenerateAuthenticationToken(tokenRequest *model.GenerateAuthenticationTokenRequest) (string, error) {
-	if tokenRequest == nil {
-		return "", errors.NewTencentCloudSDKError(
-			errorcodes.INVALIDPARAMETER_PARAMERROR, "The token request is invalid.", "")
-	}
-
-	// Create a new Signer with the provided token request.
-	s := signer.New(*tokenRequest)
-	// Get the authentication token from the cache.
-	cachedToken := s.GetAuthTokenFromCache()
-	if cachedToken != nil {
-		if cachedToken.GetExpires() > utils.GetCurrentTimeMillis() {
-			// If the token has not expired, return the token.
-			return cachedToken.GetAuthToken(), nil
-		}
-	}
-
-	err := s.BuildAuthToken()
-	if err == nil {
-		return s.GetAuthTokenFromCache().GetAuthToken(), nil
-	} else {
-		logging.Error("Error occurred while generating authentication token", err)
-		if cachedToken != nil {
-			if errorcode.IsUserNotificationRequired(err) {
-				// If the error code requires user notification, return the error.
-				return "", err
-			}
-			// If the error code does not require user notification, return the cached token.
-			return cachedToken.GetAuthToken(), nil
-		}
-		// If there is no cached token, return the error.
-		return "", err
-	}
+	return defaultClient.GenerateAuthenticationToken(tokenRequest)
+}
+
+// GenerateAuthenticationTokenLease generates an authentication token based on the provided token request and
+// returns it together with the time until which it is valid.
+func GenerateAuthenticationTokenLease(
+	tokenRequest *model.GenerateAuthenticationTokenRequest) (*model.AuthTokenLease, error) {
+	return defaultClient.GenerateAuthenticationTokenLease(tokenRequest)
+}
+
+// GenerateAuthenticationTokenPair generates an authentication token based on the provided token request and
+// returns it together with the token it replaced during the previous token overlap.
+func GenerateAuthenticationTokenPair(
+	tokenRequest *model.GenerateAuthenticationTokenRequest) (*model.AuthTokenPair, error) {
+	return defaultClient.GenerateAuthenticationTokenPair(tokenRequest)
+}
+
+// GenerateAuthenticationTokenDetailed generates an authentication token based on the provided token request and
+// returns it together with its expiry, the source it was served from and the metadata embedded by CAM.
+func GenerateAuthenticationTokenDetailed(
+	tokenRequest *model.GenerateAuthenticationTokenRequest) (*model.AuthTokenResult, error) {
+	return defaultClient.GenerateAuthenticationTokenDetailed(tokenRequest)
+}
+
+// ClockSkew returns the latest estimate of the offset of the CAM clock from the local clock, as seen by the
+// package-level functions.
+func ClockSkew() time.Duration {
+	return defaultClient.ClockSkew()
 }
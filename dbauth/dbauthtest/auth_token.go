@@ -0,0 +1,69 @@
+package dbauthtest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+// tokenHeader is the zero header which precedes the AuthTokenInfo in the decrypted tokens minted by NewAuthToken,
+// decoded as an envelope of version 0.
+var tokenHeader = []byte{0, 0, 0, 0}
+
+// maxPayloadLength is the largest payload length an envelope header can describe.
+const maxPayloadLength = 1<<24 - 1
+
+// NewAuthToken mints an authentication token of the identity in the format issued by CAM: the hex encoded
+// SHA-256 hash of the decrypted token followed by the decrypted token, encrypted with AES-CBC under the key of
+// the identity and encoded in unpadded URL-safe base64.
+func NewAuthToken(instanceId, region, userName string, tokenInfo *pb.AuthTokenInfo) (string, error) {
+	if tokenInfo == nil {
+		return "", errors.New("param empty")
+	}
+	payload, err := proto.Marshal(tokenInfo)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal AuthTokenInfo: %w", err)
+	}
+	return newAuthToken(instanceId, region, userName, append(append([]byte{}, tokenHeader...), payload...))
+}
+
+// NewVersionedAuthToken mints an authentication token of the identity like NewAuthToken, whose decrypted token is
+// an envelope of the version: a 4 bytes big-endian header of the version in the high byte and the payload length
+// in the low 3 bytes, followed by the payload.
+func NewVersionedAuthToken(instanceId, region, userName string, version uint8, payload []byte) (string, error) {
+	if len(payload) > maxPayloadLength {
+		return "", fmt.Errorf("payload length %d exceeds %d", len(payload), maxPayloadLength)
+	}
+	decToken := make([]byte, len(tokenHeader), len(tokenHeader)+len(payload))
+	binary.BigEndian.PutUint32(decToken, uint32(version)<<24|uint32(len(payload)))
+	return newAuthToken(instanceId, region, userName, append(decToken, payload...))
+}
+
+// newAuthToken encrypts the decrypted token under the key of the identity.
+func newAuthToken(instanceId, region, userName string, decToken []byte) (string, error) {
+	if instanceId == "" || region == "" || userName == "" {
+		return "", errors.New("param empty")
+	}
+
+	seedKey := fmt.Sprintf("%x", sha256.Sum256([]byte(
+		instanceId+constants.DELIMITER+region+constants.DELIMITER+userName)))
+	block, err := aes.NewCipher([]byte(seedKey[:32]))
+	if err != nil {
+		return "", err
+	}
+	padding := aes.BlockSize - len(decToken)%aes.BlockSize
+	plainText := append(append([]byte{}, decToken...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipherText := make([]byte, len(plainText))
+	cipher.NewCBCEncrypter(block, []byte(seedKey[33:49])).CryptBlocks(cipherText, plainText)
+
+	return fmt.Sprintf("%x", sha256.Sum256(decToken)) + base64.RawURLEncoding.EncodeToString(cipherText), nil
+}
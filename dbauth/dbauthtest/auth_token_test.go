@@ -0,0 +1,44 @@
+package dbauthtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/parser"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+func TestNewAuthToken_Parsed(t *testing.T) {
+	token, err := NewAuthToken("cdb-123456", "ap-guangzhou", "camtest", &pb.AuthTokenInfo{
+		InstanceId: "cdb-123456", Region: "ap-guangzhou", Username: "camtest", Password: "secret", TokenType: 1})
+	assert.NoError(t, err)
+
+	tokenInfo, err := parser.ParseAuthToken("cdb-123456", "ap-guangzhou", "camtest", token)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", tokenInfo.Password)
+	assert.Equal(t, uint32(1), tokenInfo.TokenType)
+}
+
+func TestNewVersionedAuthToken_Parsed(t *testing.T) {
+	payload, err := proto.Marshal(&pb.AuthTokenInfo{
+		InstanceId: "cdb-123456", Region: "ap-guangzhou", Username: "camtest", Password: "secret"})
+	assert.NoError(t, err)
+
+	token, err := NewVersionedAuthToken("cdb-123456", "ap-guangzhou", "camtest", parser.EnvelopeVersion0, payload)
+	assert.NoError(t, err)
+	tokenInfo, err := parser.ParseAuthToken("cdb-123456", "ap-guangzhou", "camtest", token)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", tokenInfo.Password)
+
+	token, err = NewVersionedAuthToken("cdb-123456", "ap-guangzhou", "camtest", 9, payload)
+	assert.NoError(t, err)
+	_, err = parser.ParseAuthToken("cdb-123456", "ap-guangzhou", "camtest", token)
+	assert.True(t, errors.Is(err, parser.ErrUnsupportedTokenFormat))
+}
+
+func TestNewAuthToken_EmptyParam(t *testing.T) {
+	_, err := NewAuthToken("", "ap-guangzhou", "camtest", &pb.AuthTokenInfo{Password: "secret"})
+	assert.Error(t, err)
+}
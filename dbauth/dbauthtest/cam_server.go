@@ -0,0 +1,359 @@
+package dbauthtest
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+	cam "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+)
+
+const (
+	// ErrorCodeAuthFailure is the error code injected by InjectAuthFailure.
+	ErrorCodeAuthFailure = "AuthFailure.SignatureFailure"
+	// ErrorCodeDataFlowAuthClose is the error code injected by InjectDataFlowAuthClose.
+	ErrorCodeDataFlowAuthClose = cam.RESOURCENOTFOUND_DATAFLOWAUTHCLOSE
+	// ErrorCodeRequestLimitExceeded is the error code injected by InjectThrottling.
+	ErrorCodeRequestLimitExceeded = "RequestLimitExceeded"
+
+	buildDataFlowAuthTokenAction = "BuildDataFlowAuthToken"
+	defaultTokenLifetime         = 15 * time.Minute
+)
+
+// CamServer is an in-process stand-in for the CAM API which implements BuildDataFlowAuthToken. It mints tokens in
+// the format issued by CAM, so that they are accepted by the dbauth package, and can inject errors and latency.
+type CamServer struct {
+	server *httptest.Server
+
+	mu               sync.Mutex
+	clock            clock.Clock
+	tokenLifetime    time.Duration
+	nextRotationTime time.Time
+	password         string
+	latency          time.Duration
+	unreachable      bool
+	injectedErrors   []injectedError
+	requestCount     int
+	issuedCount      int
+	connectionCount  int
+	inFlight         int
+	maxInFlight      int
+}
+
+// injectedError is an error returned for the next count requests, or for every request if count is negative.
+type injectedError struct {
+	code    string
+	message string
+	count   int
+}
+
+// camRequest is the body of a BuildDataFlowAuthToken request.
+type camRequest struct {
+	ResourceId      string `json:"ResourceId"`
+	ResourceRegion  string `json:"ResourceRegion"`
+	ResourceAccount string `json:"ResourceAccount"`
+}
+
+// NewCamServer starts a new CamServer. It must be closed with Close.
+func NewCamServer() *CamServer {
+	s := newCamServer()
+	s.server.Start()
+	return s
+}
+
+// NewTLSCamServer starts a new CamServer which serves HTTPS with a self-signed certificate, trusted by the
+// RootCAs of the server. It must be closed with Close.
+func NewTLSCamServer() *CamServer {
+	s := newCamServer()
+	s.server.StartTLS()
+	return s
+}
+
+func newCamServer() *CamServer {
+	s := &CamServer{clock: clock.System, tokenLifetime: defaultTokenLifetime}
+	s.server = httptest.NewUnstartedServer(http.HandlerFunc(s.handle))
+	s.server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			s.mu.Lock()
+			s.connectionCount++
+			s.mu.Unlock()
+		}
+	}
+	return s
+}
+
+// Close shuts the server down.
+func (s *CamServer) Close() {
+	s.server.Close()
+}
+
+// URL returns the base URL of the server.
+func (s *CamServer) URL() string {
+	return s.server.URL
+}
+
+// Endpoint returns the host and port of the server, to be used as the HttpProfile endpoint.
+func (s *CamServer) Endpoint() string {
+	return strings.TrimPrefix(strings.TrimPrefix(s.server.URL, "http://"), "https://")
+}
+
+// RootCAs returns a new certificate pool which trusts the certificate of a server started with NewTLSCamServer.
+func (s *CamServer) RootCAs() *x509.CertPool {
+	pool := x509.NewCertPool()
+	if certificate := s.server.Certificate(); certificate != nil {
+		pool.AddCert(certificate)
+	}
+	return pool
+}
+
+// ClientProfile returns a new client profile which sends the CAM requests to the server.
+func (s *CamServer) ClientProfile() *profile.ClientProfile {
+	clientProfile := profile.NewClientProfile()
+	clientProfile.HttpProfile.Scheme = "HTTP"
+	if s.server.TLS != nil {
+		clientProfile.HttpProfile.Scheme = "HTTPS"
+	}
+	clientProfile.HttpProfile.Endpoint = s.Endpoint()
+	clientProfile.HttpProfile.ReqTimeout = 5
+	return clientProfile
+}
+
+// SetClock sets the clock which gives the CurrentTime of the responses and the creation time of the tokens.
+func (s *CamServer) SetClock(serverClock clock.Clock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clock = serverClock
+}
+
+// SetTokenLifetime sets the time between the CurrentTime and the NextRotationTime of the responses,
+// 15 minutes by default.
+func (s *CamServer) SetTokenLifetime(lifetime time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenLifetime = lifetime
+}
+
+// SetNextRotationTime sets a fixed NextRotationTime for the responses. The zero time restores the token lifetime.
+func (s *CamServer) SetNextRotationTime(nextRotationTime time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextRotationTime = nextRotationTime
+}
+
+// SetPassword sets the password carried by the issued tokens. By default every token carries a new password.
+func (s *CamServer) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// SetLatency sets how long the server waits before answering a request.
+func (s *CamServer) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// SetUnreachable makes the server close the connection of every request without answering, as an unreachable
+// endpoint, until it is called with false. The dropped requests are not counted by RequestCount.
+func (s *CamServer) SetUnreachable(unreachable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unreachable = unreachable
+}
+
+// InjectError makes the server answer the next count requests with the provided error code.
+// A negative count answers every request with the error until ClearErrors is called.
+func (s *CamServer) InjectError(code, message string, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injectedErrors = append(s.injectedErrors, injectedError{code: code, message: message, count: count})
+}
+
+// InjectAuthFailure makes the server answer the next count requests with an AuthFailure error.
+func (s *CamServer) InjectAuthFailure(count int) {
+	s.InjectError(ErrorCodeAuthFailure, "The request signature is invalid.", count)
+}
+
+// InjectDataFlowAuthClose makes the server answer the next count requests as if CAM authentication was disabled.
+func (s *CamServer) InjectDataFlowAuthClose(count int) {
+	s.InjectError(ErrorCodeDataFlowAuthClose, "The data flow authentication is closed.", count)
+}
+
+// InjectThrottling makes the server answer the next count requests with a RequestLimitExceeded error.
+func (s *CamServer) InjectThrottling(count int) {
+	s.InjectError(ErrorCodeRequestLimitExceeded, "The request frequency exceeds the limit.", count)
+}
+
+// ClearErrors removes the injected errors.
+func (s *CamServer) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.injectedErrors = nil
+}
+
+// RequestCount returns the number of requests received by the server.
+func (s *CamServer) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requestCount
+}
+
+// IssuedCount returns the number of tokens issued by the server.
+func (s *CamServer) IssuedCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issuedCount
+}
+
+// MaxInFlight returns the highest number of requests the server was handling at once.
+func (s *CamServer) MaxInFlight() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxInFlight
+}
+
+// ConnectionCount returns the number of connections accepted by the server.
+func (s *CamServer) ConnectionCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connectionCount
+}
+
+func (s *CamServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if s.unreachable {
+		s.mu.Unlock()
+		dropConnection(w)
+		return
+	}
+	s.requestCount++
+	s.inFlight++
+	if s.inFlight > s.maxInFlight {
+		s.maxInFlight = s.inFlight
+	}
+	requestId := fmt.Sprintf("fake-request-%d", s.requestCount)
+	latency := s.latency
+	injected := s.nextInjectedError()
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.mu.Unlock()
+	}()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	if injected != nil {
+		writeError(w, requestId, injected.code, injected.message)
+		return
+	}
+
+	if action := r.Header.Get("X-TC-Action"); action != buildDataFlowAuthTokenAction {
+		writeError(w, requestId, "InvalidAction", fmt.Sprintf("The action %q is not supported.", action))
+		return
+	}
+
+	var request camRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, requestId, cam.INVALIDPARAMETER_PARAMERROR, "The request body is invalid.")
+		return
+	}
+	if request.ResourceId == "" || request.ResourceRegion == "" || request.ResourceAccount == "" {
+		writeError(w, requestId, cam.INVALIDPARAMETER_PARAMERROR, "The resource is invalid.")
+		return
+	}
+
+	s.mu.Lock()
+	s.issuedCount++
+	now := s.clock.Now()
+	nextRotationTime := s.nextRotationTime
+	if nextRotationTime.IsZero() {
+		nextRotationTime = now.Add(s.tokenLifetime)
+	}
+	password := s.password
+	if password == "" {
+		password = fmt.Sprintf("fake-password-%d", s.issuedCount)
+	}
+	s.mu.Unlock()
+
+	authToken, err := NewAuthToken(request.ResourceId, request.ResourceRegion, request.ResourceAccount,
+		&pb.AuthTokenInfo{
+			ReqId:      requestId,
+			InstanceId: request.ResourceId,
+			Region:     request.ResourceRegion,
+			Username:   request.ResourceAccount,
+			Password:   password,
+			CreateTime: uint64(toMillis(now)),
+		})
+	if err != nil {
+		writeError(w, requestId, cam.FAILEDOPERATION_BUILDAUTHTOKEN, err.Error())
+		return
+	}
+
+	currentTime, rotationTime := toMillis(now), toMillis(nextRotationTime)
+	writeResponse(w, map[string]interface{}{
+		"Credentials": &cam.AuthToken{
+			Token:            &authToken,
+			CurrentTime:      &currentTime,
+			NextRotationTime: &rotationTime,
+		},
+		"RequestId": requestId,
+	})
+}
+
+// nextInjectedError returns the injected error for the current request, nil if there is none.
+// It must be called with the lock held.
+func (s *CamServer) nextInjectedError() *injectedError {
+	if len(s.injectedErrors) == 0 {
+		return nil
+	}
+	injected := s.injectedErrors[0]
+	if injected.count > 0 {
+		s.injectedErrors[0].count--
+		if s.injectedErrors[0].count == 0 {
+			s.injectedErrors = s.injectedErrors[1:]
+		}
+	} else if injected.count == 0 {
+		s.injectedErrors = s.injectedErrors[1:]
+		return s.nextInjectedError()
+	}
+	return &injected
+}
+
+// dropConnection closes the connection of the request without writing a response.
+func dropConnection(w http.ResponseWriter) {
+	if hijacker, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hijacker.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	panic(http.ErrAbortHandler)
+}
+
+func writeError(w http.ResponseWriter, requestId, code, message string) {
+	writeResponse(w, map[string]interface{}{
+		"Error":     map[string]string{"Code": code, "Message": message},
+		"RequestId": requestId,
+	})
+}
+
+func writeResponse(w http.ResponseWriter, response map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"Response": response})
+}
+
+func toMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
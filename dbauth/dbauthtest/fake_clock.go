@@ -0,0 +1,114 @@
+// Package dbauthtest provides utilities for testing code which uses the dbauth package.
+package dbauthtest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+)
+
+// FakeClock is a clock.Clock whose time only moves when it is advanced. The functions scheduled with AfterFunc
+// are called synchronously by Advance and Set, in the order of their deadlines.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    int
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock    *FakeClock
+	deadline time.Time
+	// seq orders the timers with the same deadline by creation.
+	seq int
+	f   func()
+}
+
+// NewFakeClock creates a new FakeClock set to the provided time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc schedules f to be called once the clock has been advanced by d.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) clock.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	timer := &fakeTimer{clock: c, deadline: c.now.Add(d), seq: c.seq, f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d, calling the scheduled functions which become due on the way,
+// including the ones they schedule.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to the provided time, calling the scheduled functions which become due on the way.
+// A time before the current one steps the clock back without calling any function.
+func (c *FakeClock) Set(now time.Time) {
+	for {
+		c.mu.Lock()
+		timer := c.nextDueTimer(now)
+		if timer == nil {
+			c.now = now
+			c.mu.Unlock()
+			return
+		}
+		if timer.deadline.After(c.now) {
+			c.now = timer.deadline
+		}
+		c.mu.Unlock()
+
+		timer.f()
+	}
+}
+
+// PendingTimers returns the number of scheduled functions which have not been called or stopped.
+func (c *FakeClock) PendingTimers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// nextDueTimer removes and returns the earliest timer due at the provided time, nil if there is none.
+// It must be called with the lock held.
+func (c *FakeClock) nextDueTimer(now time.Time) *fakeTimer {
+	sort.SliceStable(c.timers, func(i, j int) bool {
+		if c.timers[i].deadline.Equal(c.timers[j].deadline) {
+			return c.timers[i].seq < c.timers[j].seq
+		}
+		return c.timers[i].deadline.Before(c.timers[j].deadline)
+	})
+	if len(c.timers) == 0 || c.timers[0].deadline.After(now) {
+		return nil
+	}
+	timer := c.timers[0]
+	c.timers = c.timers[1:]
+	return timer
+}
+
+// Stop removes the timer from the clock.
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	for i, timer := range t.clock.timers {
+		if timer == t {
+			t.clock.timers = append(t.clock.timers[:i], t.clock.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package dbauthtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeClock_Advance(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	fakeClock := NewFakeClock(start)
+
+	fakeClock.Advance(time.Minute)
+
+	assert.Equal(t, start.Add(time.Minute), fakeClock.Now())
+}
+
+func TestFakeClock_AfterFuncCalledWhenDue(t *testing.T) {
+	fakeClock := NewFakeClock(time.Unix(1700000000, 0))
+	var calls []string
+	fakeClock.AfterFunc(2*time.Second, func() { calls = append(calls, "second") })
+	fakeClock.AfterFunc(time.Second, func() { calls = append(calls, "first") })
+
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.Empty(t, calls)
+
+	fakeClock.Advance(2 * time.Second)
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+}
+
+func TestFakeClock_AfterFuncSeesDeadline(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	fakeClock := NewFakeClock(start)
+	var calledAt time.Time
+	fakeClock.AfterFunc(time.Second, func() { calledAt = fakeClock.Now() })
+
+	fakeClock.Advance(time.Minute)
+
+	assert.Equal(t, start.Add(time.Second), calledAt)
+}
+
+func TestFakeClock_RescheduledFunctionsRunWithinAdvance(t *testing.T) {
+	fakeClock := NewFakeClock(time.Unix(1700000000, 0))
+	calls := 0
+	var tick func()
+	tick = func() {
+		calls++
+		fakeClock.AfterFunc(time.Second, tick)
+	}
+	fakeClock.AfterFunc(time.Second, tick)
+
+	fakeClock.Advance(3500 * time.Millisecond)
+
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, 1, fakeClock.PendingTimers())
+}
+
+func TestFakeClock_Stop(t *testing.T) {
+	fakeClock := NewFakeClock(time.Unix(1700000000, 0))
+	called := false
+	timer := fakeClock.AfterFunc(time.Second, func() { called = true })
+
+	assert.True(t, timer.Stop())
+	assert.False(t, timer.Stop())
+	fakeClock.Advance(time.Minute)
+
+	assert.False(t, called)
+}
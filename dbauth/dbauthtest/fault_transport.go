@@ -0,0 +1,234 @@
+package dbauthtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Fault is a failure injected by a FaultTransport.
+type Fault int
+
+const (
+	// FaultNone passes the request through.
+	FaultNone Fault = iota
+	// FaultConnectionReset fails the request with a connection reset error.
+	FaultConnectionReset
+	// FaultTimeout holds the request for the timeout delay and fails it with a timeout error.
+	FaultTimeout
+	// FaultServerError answers the request with a 503 status without sending it.
+	FaultServerError
+	// FaultTruncatedBody sends the request and cuts the response body in half.
+	FaultTruncatedBody
+	// FaultMalformedToken sends the request and corrupts the token of a BuildDataFlowAuthToken response.
+	FaultMalformedToken
+)
+
+// String returns the name of the fault.
+func (f Fault) String() string {
+	switch f {
+	case FaultNone:
+		return "None"
+	case FaultConnectionReset:
+		return "ConnectionReset"
+	case FaultTimeout:
+		return "Timeout"
+	case FaultServerError:
+		return "ServerError"
+	case FaultTruncatedBody:
+		return "TruncatedBody"
+	case FaultMalformedToken:
+		return "MalformedToken"
+	default:
+		return "Unknown"
+	}
+}
+
+// faults lists the faults drawn by their rates, in a fixed order so that a seed always gives the same faults.
+var faults = []Fault{FaultConnectionReset, FaultTimeout, FaultServerError, FaultTruncatedBody, FaultMalformedToken}
+
+// FaultTransport is an http.RoundTripper which injects faults into the requests it passes to another one.
+// The faults queued with Schedule are injected first, in order. Then each request draws a fault by the rates set
+// with SetRate from a random source seeded at creation, so that a seed always injects the same sequence of faults.
+// It can be installed in the CAM client with model.ClientOptions.Transport.
+type FaultTransport struct {
+	base http.RoundTripper
+
+	mu           sync.Mutex
+	random       *rand.Rand
+	rates        map[Fault]float64
+	scheduled    []Fault
+	counts       map[Fault]int
+	timeoutDelay time.Duration
+}
+
+// errConnectionReset is the error of a request failed with FaultConnectionReset. It is not the errno of the
+// platform, which some platforms such as plan9 do not define.
+var errConnectionReset = errors.New("connection reset by peer")
+
+// timeoutError is the error of a request failed with FaultTimeout.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "dbauthtest: injected timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+// NewFaultTransport creates a new FaultTransport which sends the requests with base, or with
+// http.DefaultTransport if base is nil, and draws the faults from a random source with the provided seed.
+func NewFaultTransport(base http.RoundTripper, seed int64) *FaultTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &FaultTransport{
+		base:   base,
+		random: rand.New(rand.NewSource(seed)),
+		rates:  map[Fault]float64{},
+		counts: map[Fault]int{},
+	}
+}
+
+// SetRate sets the probability, between 0 and 1, that a request not covered by Schedule gets the fault.
+func (t *FaultTransport) SetRate(fault Fault, rate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rates[fault] = rate
+}
+
+// Schedule queues faults to be injected into the next requests, one per request.
+func (t *FaultTransport) Schedule(faults ...Fault) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.scheduled = append(t.scheduled, faults...)
+}
+
+// SetTimeoutDelay sets how long a request with FaultTimeout is held before it fails, unless its context ends first.
+func (t *FaultTransport) SetTimeoutDelay(delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timeoutDelay = delay
+}
+
+// Count returns the number of requests which got the fault.
+func (t *FaultTransport) Count(fault Fault) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[fault]
+}
+
+// RoundTrip sends the request with the next fault injected.
+func (t *FaultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fault, timeoutDelay := t.nextFault()
+
+	switch fault {
+	case FaultConnectionReset:
+		closeBody(req)
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: errConnectionReset}
+	case FaultTimeout:
+		closeBody(req)
+		select {
+		case <-time.After(timeoutDelay):
+		case <-req.Context().Done():
+		}
+		return nil, &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}
+	case FaultServerError:
+		closeBody(req)
+		return newResponse(req, http.StatusServiceUnavailable, []byte("Service Unavailable")), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || (fault != FaultTruncatedBody && fault != FaultMalformedToken) {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if fault == FaultTruncatedBody {
+		body = body[:len(body)/2]
+	} else {
+		body = corruptToken(body)
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// nextFault returns the fault of the next request and the timeout delay.
+func (t *FaultTransport) nextFault() (Fault, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	fault := FaultNone
+	if len(t.scheduled) > 0 {
+		fault, t.scheduled = t.scheduled[0], t.scheduled[1:]
+	} else {
+		draw := t.random.Float64()
+		for _, candidate := range faults {
+			if draw < t.rates[candidate] {
+				fault = candidate
+				break
+			}
+			draw -= t.rates[candidate]
+		}
+	}
+	t.counts[fault]++
+	return fault, t.timeoutDelay
+}
+
+// corruptToken flips the characters of the token of a BuildDataFlowAuthToken response body,
+// which is returned unchanged if it carries no token.
+func corruptToken(body []byte) []byte {
+	var response map[string]map[string]interface{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	credentials, ok := response["Response"]["Credentials"].(map[string]interface{})
+	if !ok {
+		return body
+	}
+	authToken, ok := credentials["Token"].(string)
+	if !ok {
+		return body
+	}
+
+	corrupted := []byte(authToken)
+	for i := len(corrupted) / 2; i < len(corrupted); i++ {
+		corrupted[i] = 'A' + (corrupted[i]+1)%26
+	}
+	credentials["Token"] = string(corrupted)
+
+	if corruptedBody, err := json.Marshal(response); err == nil {
+		return corruptedBody
+	}
+	return body
+}
+
+func newResponse(req *http.Request, statusCode int, body []byte) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		StatusCode:    statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -0,0 +1,88 @@
+package dbauthtest
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/parser"
+)
+
+func newTestCamRequest(t *testing.T, server *CamServer) *http.Request {
+	body := `{"ResourceId":"cdb-123456","ResourceRegion":"ap-guangzhou","ResourceAccount":"camtest"}`
+	req, err := http.NewRequest(http.MethodPost, server.URL(), strings.NewReader(body))
+	assert.NoError(t, err)
+	req.Header.Set("X-TC-Action", "BuildDataFlowAuthToken")
+	return req
+}
+
+func TestFaultTransport_ScheduledFaults(t *testing.T) {
+	server := NewCamServer()
+	defer server.Close()
+	transport := NewFaultTransport(nil, 1)
+	transport.Schedule(FaultConnectionReset, FaultTimeout, FaultServerError, FaultTruncatedBody)
+
+	_, err := transport.RoundTrip(newTestCamRequest(t, server))
+	var opErr *net.OpError
+	assert.True(t, errors.As(err, &opErr))
+
+	_, err = transport.RoundTrip(newTestCamRequest(t, server))
+	var netErr net.Error
+	assert.True(t, errors.As(err, &netErr))
+	assert.True(t, netErr.Timeout())
+
+	resp, err := transport.RoundTrip(newTestCamRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	resp, err = transport.RoundTrip(newTestCamRequest(t, server))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, server.RequestCount())
+	assert.Equal(t, 1, transport.Count(FaultTruncatedBody))
+}
+
+func TestFaultTransport_MalformedTokenRejectedByParser(t *testing.T) {
+	server := NewCamServer()
+	defer server.Close()
+	transport := NewFaultTransport(nil, 1)
+	transport.Schedule(FaultMalformedToken)
+
+	resp, err := transport.RoundTrip(newTestCamRequest(t, server))
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	var response struct {
+		Response struct {
+			Credentials struct {
+				Token string
+			}
+		}
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&response))
+	_, err = parser.ParseAuthToken("cdb-123456", "ap-guangzhou", "camtest", response.Response.Credentials.Token)
+	assert.Error(t, err)
+}
+
+func TestFaultTransport_SeededRatesAreReproducible(t *testing.T) {
+	draw := func() []Fault {
+		transport := NewFaultTransport(nil, 42)
+		transport.SetRate(FaultServerError, 0.3)
+		transport.SetRate(FaultConnectionReset, 0.2)
+		var drawn []Fault
+		for i := 0; i < 20; i++ {
+			fault, _ := transport.nextFault()
+			drawn = append(drawn, fault)
+		}
+		return drawn
+	}
+
+	first := draw()
+	assert.Equal(t, first, draw())
+	assert.Contains(t, first, FaultServerError)
+	assert.Contains(t, first, FaultNone)
+}
@@ -0,0 +1,80 @@
+package dbauthtest
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsRecorder is a model.MetricsRecorder which keeps the recorded metrics in memory, so that tests can check
+// them. The metrics are looked up by name and labels, nil labels matching the metrics recorded without labels.
+type MetricsRecorder struct {
+	mu        sync.Mutex
+	counters  map[string]int
+	gauges    map[string]float64
+	durations map[string][]time.Duration
+}
+
+// NewMetricsRecorder creates a new MetricsRecorder.
+func NewMetricsRecorder() *MetricsRecorder {
+	return &MetricsRecorder{
+		counters:  map[string]int{},
+		gauges:    map[string]float64{},
+		durations: map[string][]time.Duration{},
+	}
+}
+
+// IncCounter increments the counter.
+func (r *MetricsRecorder) IncCounter(name string, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counters[metricKey(name, labels)]++
+}
+
+// SetGauge sets the gauge.
+func (r *MetricsRecorder) SetGauge(name string, value float64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges[metricKey(name, labels)] = value
+}
+
+// ObserveDuration records the duration.
+func (r *MetricsRecorder) ObserveDuration(name string, duration time.Duration, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := metricKey(name, labels)
+	r.durations[key] = append(r.durations[key], duration)
+}
+
+// Counter returns the value of the counter, zero if it was never incremented.
+func (r *MetricsRecorder) Counter(name string, labels map[string]string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.counters[metricKey(name, labels)]
+}
+
+// Gauge returns the value of the gauge and whether it was set.
+func (r *MetricsRecorder) Gauge(name string, labels map[string]string) (float64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	value, ok := r.gauges[metricKey(name, labels)]
+	return value, ok
+}
+
+// Durations returns the recorded durations, in the order they were recorded.
+func (r *MetricsRecorder) Durations(name string, labels map[string]string) []time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]time.Duration(nil), r.durations[metricKey(name, labels)]...)
+}
+
+// metricKey returns the key of the metric with the provided name and labels, independent of the label order.
+func metricKey(name string, labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for label, value := range labels {
+		pairs = append(pairs, label+"="+value)
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,29 @@
+package dbauthtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+func TestMetricsRecorder_KeysByNameAndLabels(t *testing.T) {
+	var recorder model.MetricsRecorder = NewMetricsRecorder()
+	recorder.IncCounter("requests", map[string]string{"endpoint": "a", "result": "ok"})
+	recorder.IncCounter("requests", map[string]string{"result": "ok", "endpoint": "a"})
+	recorder.IncCounter("requests", nil)
+	recorder.SetGauge("rate", 5, nil)
+	recorder.ObserveDuration("delay", time.Second, nil)
+	recorder.ObserveDuration("delay", 2*time.Second, nil)
+
+	metrics := recorder.(*MetricsRecorder)
+	assert.Equal(t, 2, metrics.Counter("requests", map[string]string{"endpoint": "a", "result": "ok"}))
+	assert.Equal(t, 1, metrics.Counter("requests", nil))
+	rate, ok := metrics.Gauge("rate", nil)
+	assert.True(t, ok)
+	assert.Equal(t, 5.0, rate)
+	_, ok = metrics.Gauge("rate", map[string]string{"endpoint": "a"})
+	assert.False(t, ok)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second}, metrics.Durations("delay", nil))
+}
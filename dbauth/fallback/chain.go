@@ -0,0 +1,46 @@
+package fallback
+
+import (
+	"io"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+// Chain is a FallbackProvider which asks its providers in order and returns the first password provided. An
+// empty Chain provides no password, which disables the fallback of a client.
+type Chain []model.FallbackProvider
+
+// NewChain creates a new Chain of the providers.
+func NewChain(providers ...model.FallbackProvider) Chain {
+	return providers
+}
+
+// FallbackPassword returns the first password provided by the providers of the chain. The error of a provider is
+// logged and the next provider is asked. It returns nil if no provider has a password.
+func (c Chain) FallbackPassword(request *model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+	for _, provider := range c {
+		password, err := provider.FallbackPassword(request)
+		if err != nil {
+			logging.Errorf("Failed to get the fallback password, error: %v", err)
+			continue
+		}
+		if password != nil {
+			return password, nil
+		}
+	}
+	return nil, nil
+}
+
+// Close closes the providers of the chain which implement io.Closer, such as a VaultProvider or a nested Chain.
+// It returns the first error.
+func (c Chain) Close() error {
+	var firstErr error
+	for _, provider := range c {
+		if closer, ok := provider.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,198 @@
+// Package fallback provides the built-in providers of the password served when CAM cannot issue a token.
+package fallback
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/sealer"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+// MaxPasswordSize is the maximum size in bytes of a password file.
+const MaxPasswordSize = 200
+
+var logging = logrus.WithField("component", "fallback")
+
+// DirectoryProvider reads the password of a token request from the file <region>_<instanceId>_<userName>.pwd of
+// a directory. The file either contains the password on a single line, or is an encrypted password file written by
+// WriteEncryptedFile; the format is detected from the content of the file. The file is checked with the
+// permission policy of the provider, PermissionWarn by default, and the expiry of an encrypted file with the clock
+// of the provider, clock.System by default.
+type DirectoryProvider struct {
+	dir    string
+	key    []byte
+	policy PermissionPolicy
+	clock  clock.Clock
+}
+
+// NewDirectoryProvider creates a new DirectoryProvider which reads the password files from the directory.
+// A relative directory is resolved against the working directory at each read.
+func NewDirectoryProvider(dir string) *DirectoryProvider {
+	return &DirectoryProvider{dir: dir}
+}
+
+// NewEncryptedDirectoryProvider creates a new DirectoryProvider which reads the password files from the directory
+// and decrypts the encrypted ones with the key. A DirectoryProvider created without a key reads it with KeyFromEnv.
+func NewEncryptedDirectoryProvider(dir string, key []byte) *DirectoryProvider {
+	return &DirectoryProvider{dir: dir, key: key}
+}
+
+// NewWorkingDirectoryProvider creates a new DirectoryProvider which reads the password files from the directory
+// .com.tencentcloudapi/tencentcloud-dbauth-sdk-go/input of the working directory. It is the default provider of
+// a client.
+func NewWorkingDirectoryProvider() *DirectoryProvider {
+	return NewDirectoryProvider(constants.InputPathDir)
+}
+
+// WithPermissionPolicy returns a copy of the provider which checks the password files with the policy.
+func (p *DirectoryProvider) WithPermissionPolicy(policy PermissionPolicy) *DirectoryProvider {
+	provider := *p
+	provider.policy = policy
+	return &provider
+}
+
+// WithClock returns a copy of the provider which checks the expiry of the encrypted password files with the clock.
+func (p *DirectoryProvider) WithClock(providerClock clock.Clock) *DirectoryProvider {
+	provider := *p
+	provider.clock = providerClock
+	return &provider
+}
+
+// now returns the current time of the clock of the provider, clock.System if it has none.
+func (p *DirectoryProvider) now() time.Time {
+	if p.clock == nil {
+		return clock.System.Now()
+	}
+	return p.clock.Now()
+}
+
+// Path returns the path of the password file of the token request.
+func (p *DirectoryProvider) Path(request *model.GenerateAuthenticationTokenRequest) (string, error) {
+	dir, err := p.resolvedDir()
+	if err != nil {
+		return "", err
+	}
+	name := request.Region() + constants.DELIMITER + request.InstanceId() + constants.DELIMITER +
+		request.UserName() + passwordFileSuffix
+	return filepath.Join(dir, name), nil
+}
+
+// resolvedDir returns the absolute path of the directory of the provider.
+func (p *DirectoryProvider) resolvedDir() (string, error) {
+	if filepath.IsAbs(p.dir) {
+		return filepath.Clean(p.dir), nil
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to get working directory: %w", err)
+	}
+	return filepath.Join(wd, p.dir), nil
+}
+
+// FallbackPassword reads the password of the token request from its file. It returns nil if the file does not
+// exist or is empty.
+func (p *DirectoryProvider) FallbackPassword(
+	request *model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+	path, err := p.Path(request)
+	if err != nil {
+		return nil, err
+	}
+
+	file, problems, err := openPasswordFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	logging.Infof("file name: %s, file size: %d", path, fileInfo.Size())
+	// If the file size is 0 or the file size is greater than the limit of its format, skip the file
+	if fileInfo.Size() == 0 {
+		return nil, nil
+	}
+	if err := enforcePermissions(p.policy, path, problems); err != nil {
+		return nil, err
+	}
+	if fileInfo.Size() > MaxEncryptedFileSize {
+		return nil, fmt.Errorf("the file size is greater than %d, skip the file: %s", MaxEncryptedFileSize, path)
+	}
+
+	// The file is read from the checked descriptor, at most one byte past the limit in case it has grown.
+	data, err := ioutil.ReadAll(io.LimitReader(file, MaxEncryptedFileSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) > MaxEncryptedFileSize {
+		return nil, fmt.Errorf("the file size is greater than %d, skip the file: %s", MaxEncryptedFileSize, path)
+	}
+	if sealer.IsSealed(data) {
+		return p.decryptPassword(path, data)
+	}
+	if len(data) > MaxPasswordSize {
+		return nil, fmt.Errorf("the file size is greater than %d, skip the file: %s", MaxPasswordSize, path)
+	}
+
+	lines, err := readAllLines(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) != 1 {
+		return nil, fmt.Errorf("the file contains %d lines, expected exactly one line, skip the file: %s",
+			len(lines), path)
+	}
+	return &model.FallbackPassword{Value: lines[0], Source: path}, nil
+}
+
+// decryptPassword decrypts the encrypted password file read from the path.
+func (p *DirectoryProvider) decryptPassword(path string, data []byte) (*model.FallbackPassword, error) {
+	key := p.key
+	if key == nil {
+		var err error
+		if key, err = KeyFromEnv(); err != nil {
+			return nil, err
+		}
+		if key == nil {
+			return nil, fmt.Errorf("the file %s is encrypted, but neither %s nor %s is set", path, KeyEnv, KeyFileEnv)
+		}
+	}
+
+	file, err := DecryptFile(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the file %s: %w", path, err)
+	}
+	if !file.Expiry.IsZero() && !p.now().Before(file.Expiry) {
+		return nil, fmt.Errorf("the password of the file %s expired at %s", path, file.Expiry.Format(time.RFC3339))
+	}
+	return &model.FallbackPassword{Value: file.Password, Expiry: file.Expiry, Source: path,
+		Metadata: file.Metadata}, nil
+}
+
+func readAllLines(data []byte) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	return lines, nil
+}
@@ -0,0 +1,132 @@
+package fallback
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/sealer"
+)
+
+const (
+	// KeyEnv is the environment variable holding the base64 key of the encrypted password files.
+	KeyEnv = "DBAUTH_FALLBACK_KEY"
+	// KeyFileEnv is the environment variable holding the path of the key file of the encrypted password files. It
+	// is only read if KeyEnv is not set.
+	KeyFileEnv = "DBAUTH_FALLBACK_KEY_FILE"
+	// KeySize is the size in bytes of the key of the encrypted password files.
+	KeySize = sealer.KeySize
+	// MaxEncryptedFileSize is the maximum size in bytes of an encrypted password file.
+	MaxEncryptedFileSize = 64 << 10
+)
+
+// EncryptedFile is the content of an encrypted password file. The file is sealed with AES-256-GCM and starts with
+// a magic header followed by its format version, which lets a DirectoryProvider tell it from a plaintext file.
+type EncryptedFile struct {
+	// Password is the password.
+	Password string
+	// Expiry is the time until which the password is served, or zero if it has no explicit expiry.
+	Expiry time.Time
+	// Metadata is free-form information about the password, such as who rotated it.
+	Metadata map[string]string
+}
+
+// encryptedPayload is the JSON plaintext of an encrypted password file.
+type encryptedPayload struct {
+	Password string            `json:"password"`
+	Expiry   *time.Time        `json:"expiry,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// GenerateKey returns a new random key for the encrypted password files.
+func GenerateKey() ([]byte, error) {
+	return sealer.GenerateKey()
+}
+
+// ParseKey parses a key encoded in standard base64, or given as its KeySize raw bytes.
+func ParseKey(encoded []byte) ([]byte, error) {
+	return sealer.ParseKey(encoded)
+}
+
+// ReadKeyFile reads the key stored in the file, encoded in standard base64 or as its KeySize raw bytes.
+func ReadKeyFile(path string) ([]byte, error) {
+	encoded, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the key file: %w", err)
+	}
+	key, err := ParseKey(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// KeyFromEnv returns the key of the KeyEnv environment variable, or else of the file named by the KeyFileEnv
+// environment variable. It returns nil if neither is set.
+func KeyFromEnv() ([]byte, error) {
+	if encoded := os.Getenv(KeyEnv); encoded != "" {
+		key, err := ParseKey([]byte(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", KeyEnv, err)
+		}
+		return key, nil
+	}
+	if path := os.Getenv(KeyFileEnv); path != "" {
+		return ReadKeyFile(path)
+	}
+	return nil, nil
+}
+
+// EncryptFile encrypts the content of an encrypted password file with the key.
+func EncryptFile(key []byte, file *EncryptedFile) ([]byte, error) {
+	plaintext, err := marshalEncryptedFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return sealer.Seal(key, plaintext)
+}
+
+// marshalEncryptedFile returns the JSON plaintext of an encrypted password file.
+func marshalEncryptedFile(file *EncryptedFile) ([]byte, error) {
+	if file.Password == "" {
+		return nil, fmt.Errorf("the password is empty")
+	}
+	payload := encryptedPayload{Password: file.Password, Metadata: file.Metadata}
+	if !file.Expiry.IsZero() {
+		expiry := file.Expiry.UTC()
+		payload.Expiry = &expiry
+	}
+	return json.Marshal(payload)
+}
+
+// DecryptFile decrypts the content of an encrypted password file with the key.
+func DecryptFile(key, data []byte) (*EncryptedFile, error) {
+	plaintext, err := sealer.Open(key, data)
+	if err != nil {
+		return nil, err
+	}
+	var payload encryptedPayload
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse the encrypted password file: %w", err)
+	}
+	if payload.Password == "" {
+		return nil, fmt.Errorf("the encrypted password file holds no password")
+	}
+	file := &EncryptedFile{Password: payload.Password, Metadata: payload.Metadata}
+	if payload.Expiry != nil {
+		file.Expiry = *payload.Expiry
+	}
+	return file, nil
+}
+
+// WriteEncryptedFile encrypts the content with the key and writes it to the path with the mode 0600. The file is
+// replaced atomically, so a concurrent reader never sees a partial file.
+func WriteEncryptedFile(path string, key []byte, file *EncryptedFile) error {
+	plaintext, err := marshalEncryptedFile(file)
+	if err != nil {
+		return err
+	}
+	return sealer.WriteFile(path, key, plaintext)
+}
@@ -0,0 +1,113 @@
+package fallback
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+)
+
+func writeEncryptedPasswordFile(t *testing.T, dir string, key []byte, file *EncryptedFile) string {
+	path := filepath.Join(dir, "ap-guangzhou_cdb-123456_camtest.pwd")
+	assert.NoError(t, WriteEncryptedFile(path, key, file))
+	return path
+}
+
+func TestWriteEncryptedFile(t *testing.T) {
+	key, err := GenerateKey()
+	assert.NoError(t, err)
+	expiry := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	path := writeEncryptedPasswordFile(t, t.TempDir(), key, &EncryptedFile{
+		Password: "password", Expiry: expiry, Metadata: map[string]string{"rotatedBy": "ops"}})
+
+	fileInfo, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "password")
+
+	file, err := DecryptFile(key, data)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", file.Password)
+	assert.True(t, expiry.Equal(file.Expiry))
+	assert.Equal(t, map[string]string{"rotatedBy": "ops"}, file.Metadata)
+
+	assert.Error(t, WriteEncryptedFile(path, key, &EncryptedFile{}))
+	assert.Error(t, WriteEncryptedFile(path, key[:16], &EncryptedFile{Password: "password"}))
+}
+
+func TestDirectoryProvider_ReadsEncryptedFile(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := GenerateKey()
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	path := writeEncryptedPasswordFile(t, dir, key, &EncryptedFile{
+		Password: "password", Expiry: expiry, Metadata: map[string]string{"rotatedBy": "ops"}})
+
+	password, err := NewEncryptedDirectoryProvider(dir, key).FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	assert.Equal(t, path, password.Source)
+	assert.True(t, expiry.Equal(password.Expiry))
+	assert.Equal(t, "ops", password.Metadata["rotatedBy"])
+
+	// A plaintext file is still read by a provider with a key.
+	writePasswordFile(t, dir, "plaintext\n")
+	password, err = NewEncryptedDirectoryProvider(dir, key).FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "plaintext", password.Value)
+}
+
+func TestDirectoryProvider_EncryptedFileKeyFromEnv(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := GenerateKey()
+	writeEncryptedPasswordFile(t, dir, key, &EncryptedFile{Password: "password"})
+	t.Setenv(KeyEnv, "")
+	t.Setenv(KeyFileEnv, "")
+
+	_, err := NewDirectoryProvider(dir).FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0600))
+	t.Setenv(KeyFileEnv, keyFile)
+	password, err := NewDirectoryProvider(dir).FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	assert.True(t, password.Expiry.IsZero())
+
+	otherKey, _ := GenerateKey()
+	t.Setenv(KeyEnv, base64.StdEncoding.EncodeToString(otherKey))
+	_, err = NewDirectoryProvider(dir).FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+}
+
+func TestDirectoryProvider_ExpiredEncryptedFile(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := GenerateKey()
+	writeEncryptedPasswordFile(t, dir, key, &EncryptedFile{Password: "password", Expiry: time.Now().Add(-time.Minute)})
+
+	_, err := NewEncryptedDirectoryProvider(dir, key).FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+}
+
+func TestDirectoryProvider_EncryptedFileExpiresOnClock(t *testing.T) {
+	dir := t.TempDir()
+	key, _ := GenerateKey()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	writeEncryptedPasswordFile(t, dir, key, &EncryptedFile{Password: "password", Expiry: fakeClock.Now().Add(time.Hour)})
+	provider := NewEncryptedDirectoryProvider(dir, key).WithClock(fakeClock)
+
+	password, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+
+	fakeClock.Advance(time.Hour)
+	_, err = provider.FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package fallback
+
+import (
+	"os"
+	"strings"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables read by an EnvProvider created with an empty prefix.
+const DefaultEnvPrefix = "DBAUTH_FALLBACK_PASSWORD_"
+
+// EnvProvider reads the password of a token request from an environment variable.
+type EnvProvider struct {
+	prefix string
+}
+
+// NewEnvProvider creates a new EnvProvider which reads the password of a token request from the environment
+// variable named by EnvVariableName, DefaultEnvPrefix being used if prefix is empty.
+func NewEnvProvider(prefix string) *EnvProvider {
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+	return &EnvProvider{prefix: prefix}
+}
+
+// EnvVariableName returns the name of the environment variable of the token request: the prefix followed by the
+// region, the instance id and the user name, joined by underscores, in upper case, every character other than a
+// letter or a digit being replaced by an underscore. For example, DBAUTH_FALLBACK_PASSWORD_AP_GUANGZHOU_CDB_123_ROOT.
+func (p *EnvProvider) EnvVariableName(request *model.GenerateAuthenticationTokenRequest) string {
+	key := strings.Join([]string{request.Region(), request.InstanceId(), request.UserName()}, "_")
+	return p.prefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// FallbackPassword reads the password of the token request from its environment variable. It returns nil if the
+// variable is not set or is empty.
+func (p *EnvProvider) FallbackPassword(
+	request *model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+	name := p.EnvVariableName(request)
+	if value := os.Getenv(name); value != "" {
+		return &model.FallbackPassword{Value: value, Source: "environment variable " + name}, nil
+	}
+	return nil, nil
+}
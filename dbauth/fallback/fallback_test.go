@@ -0,0 +1,113 @@
+package fallback
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+)
+
+func newTestRequest(t *testing.T) *model.GenerateAuthenticationTokenRequest {
+	request, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-123456", "camtest",
+		common.NewCredential("secretId", "secretKey"), nil)
+	assert.NoError(t, err)
+	return request
+}
+
+func writePasswordFile(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "ap-guangzhou_cdb-123456_camtest.pwd")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestDirectoryProvider_ReadsPasswordFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writePasswordFile(t, dir, "password\n")
+
+	password, err := NewDirectoryProvider(dir).FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	assert.Equal(t, path, password.Source)
+	assert.True(t, password.Expiry.IsZero())
+}
+
+func TestDirectoryProvider_MissingOrEmptyFile(t *testing.T) {
+	dir := t.TempDir()
+	password, err := NewDirectoryProvider(dir).FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Nil(t, password)
+
+	writePasswordFile(t, dir, "")
+	password, err = NewDirectoryProvider(dir).FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Nil(t, password)
+}
+
+func TestDirectoryProvider_InvalidFile(t *testing.T) {
+	dir := t.TempDir()
+	writePasswordFile(t, dir, "first\nsecond\n")
+	_, err := NewDirectoryProvider(dir).FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+
+	writePasswordFile(t, dir, string(make([]byte, MaxPasswordSize+1)))
+	_, err = NewDirectoryProvider(dir).FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+}
+
+func TestWorkingDirectoryProvider_Path(t *testing.T) {
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	path, err := NewWorkingDirectoryProvider().Path(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(wd, ".com.tencentcloudapi/tencentcloud-dbauth-sdk-go/input",
+		"ap-guangzhou_cdb-123456_camtest.pwd"), path)
+}
+
+func TestEnvProvider_ReadsVariable(t *testing.T) {
+	provider := NewEnvProvider("")
+	name := provider.EnvVariableName(newTestRequest(t))
+	assert.Equal(t, "DBAUTH_FALLBACK_PASSWORD_AP_GUANGZHOU_CDB_123456_CAMTEST", name)
+
+	password, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Nil(t, password)
+
+	t.Setenv(name, "password")
+	password, err = provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	assert.Equal(t, "environment variable "+name, password.Source)
+}
+
+func TestChain_ReturnsFirstPassword(t *testing.T) {
+	var asked []string
+	provider := func(name string, password *model.FallbackPassword, err error) model.FallbackProvider {
+		return Func(func(*model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+			asked = append(asked, name)
+			return password, err
+		})
+	}
+
+	chain := NewChain(
+		provider("failing", nil, errors.New("unavailable")),
+		provider("empty", nil, nil),
+		provider("static", &model.FallbackPassword{Value: "password"}, nil),
+		provider("unused", &model.FallbackPassword{Value: "other"}, nil),
+	)
+	password, err := chain.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	assert.Equal(t, []string{"failing", "empty", "static"}, asked)
+}
+
+func TestChain_Empty(t *testing.T) {
+	password, err := NewChain().FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Nil(t, password)
+}
@@ -0,0 +1,61 @@
+package fallback
+
+import (
+	"sync"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+// secretFlight deduplicates the concurrent reads of a secret, so that a secret is read once however many token
+// requests need it at the same time, without holding a lock during the read.
+type secretFlight struct {
+	mu    sync.Mutex
+	calls map[string]*secretCall
+}
+
+// secretCall is a read of a secret in flight.
+type secretCall struct {
+	done chan struct{}
+	// waiters is the number of calls waiting for the result of the read.
+	waiters  int
+	password *model.FallbackPassword
+	err      error
+}
+
+// do calls read for the secret, unless a read of the secret is already in flight, in which case it waits for
+// that read and returns its result.
+func (f *secretFlight) do(secret string,
+	read func() (*model.FallbackPassword, error)) (*model.FallbackPassword, error) {
+	f.mu.Lock()
+	if call, ok := f.calls[secret]; ok {
+		call.waiters++
+		f.mu.Unlock()
+		<-call.done
+		return call.password, call.err
+	}
+	if f.calls == nil {
+		f.calls = map[string]*secretCall{}
+	}
+	call := &secretCall{done: make(chan struct{})}
+	f.calls[secret] = call
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.calls, secret)
+		f.mu.Unlock()
+		close(call.done)
+	}()
+	call.password, call.err = read()
+	return call.password, call.err
+}
+
+// waiters returns the number of calls waiting for the read of the secret in flight.
+func (f *secretFlight) waiters(secret string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if call, ok := f.calls[secret]; ok {
+		return call.waiters
+	}
+	return 0
+}
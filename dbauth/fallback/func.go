@@ -0,0 +1,12 @@
+package fallback
+
+import "github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+
+// Func is a function used as a FallbackProvider, such as a callback which looks the password up in an application
+// specific store.
+type Func func(request *model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error)
+
+// FallbackPassword calls f(request).
+func (f Func) FallbackPassword(request *model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+	return f(request)
+}
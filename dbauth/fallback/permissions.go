@@ -0,0 +1,83 @@
+package fallback
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PermissionPolicy is how a DirectoryProvider handles the password files which other users could read or replace:
+// the files accessible by their group or other users, owned by another user, or reached through a symlink.
+type PermissionPolicy int
+
+const (
+	// PermissionWarn logs a warning and reads the file. It is the default policy.
+	PermissionWarn PermissionPolicy = iota
+	// PermissionStrict refuses to read the file.
+	PermissionStrict
+	// PermissionOff reads the file without checking it.
+	PermissionOff
+)
+
+// String returns the name of the policy.
+func (p PermissionPolicy) String() string {
+	switch p {
+	case PermissionWarn:
+		return "warn"
+	case PermissionStrict:
+		return "strict"
+	case PermissionOff:
+		return "off"
+	default:
+		return fmt.Sprintf("PermissionPolicy(%d)", int(p))
+	}
+}
+
+// enforcePermissions checks the problems of the password file at the path with the policy. It returns an error if
+// the file is unsafe and the policy is strict.
+func enforcePermissions(policy PermissionPolicy, path string, problems []string) error {
+	if policy == PermissionOff || len(problems) == 0 {
+		return nil
+	}
+
+	message := fmt.Sprintf("the file %s is unsafe: %s", path, strings.Join(problems, ", "))
+	if policy == PermissionStrict {
+		return fmt.Errorf("%s, skip the file", message)
+	}
+	logging.Warnf("%s, the permission policy %s will refuse it", message, PermissionStrict)
+	return nil
+}
+
+// openPasswordFile opens the password file at the path without following a symlink, and returns the reasons why
+// other users could read or replace it. The mode and the owner are checked on the opened file, so that the file
+// cannot be replaced between the checks and the read. A symlink is reported, then opened through.
+func openPasswordFile(path string) (*os.File, []string, error) {
+	var problems []string
+	dirInfo, err := os.Lstat(filepath.Dir(path))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to stat directory %s: %w", filepath.Dir(path), err)
+	}
+	if dirInfo.Mode()&os.ModeSymlink != 0 {
+		problems = append(problems, "its directory is a symlink")
+	}
+
+	file, err := openNoFollow(path)
+	if err != nil {
+		linkInfo, linkErr := os.Lstat(path)
+		if linkErr != nil || linkInfo.Mode()&os.ModeSymlink == 0 {
+			return nil, nil, fmt.Errorf("failed to open file %s: %w", path, err)
+		}
+		problems = append(problems, "it is a symlink")
+		if file, err = os.Open(path); err != nil {
+			return nil, nil, fmt.Errorf("failed to open file %s: %w", path, err)
+		}
+	}
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	return file, append(problems, ownershipProblems(fileInfo)...), nil
+}
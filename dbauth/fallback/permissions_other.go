@@ -0,0 +1,28 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package fallback
+
+import (
+	"fmt"
+	"os"
+)
+
+// ownershipProblems returns no problem, the mode bits and the owner of a file not describing who can access it on
+// this platform.
+func ownershipProblems(os.FileInfo) []string {
+	return nil
+}
+
+// openNoFollow opens the file for reading, failing if the path is a symlink. There is no O_NOFOLLOW on this
+// platform, so the path is checked before it is opened.
+func openNoFollow(path string) (*os.File, error) {
+	linkInfo, err := os.Lstat(path)
+	if err != nil {
+		return nil, err
+	}
+	if linkInfo.Mode()&os.ModeSymlink != 0 {
+		return nil, fmt.Errorf("the file %s is a symlink", path)
+	}
+	return os.Open(path)
+}
@@ -0,0 +1,31 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package fallback
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// ownershipProblems returns the reasons why the mode or the owner of the file are unsafe.
+func ownershipProblems(fileInfo os.FileInfo) []string {
+	var problems []string
+	if perm := fileInfo.Mode().Perm(); perm&0077 != 0 {
+		problems = append(problems, fmt.Sprintf("it is accessible by its group or other users, mode %04o", perm))
+	}
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return problems
+	}
+	if uid := os.Geteuid(); int(stat.Uid) != uid {
+		problems = append(problems, fmt.Sprintf("it is owned by uid %d instead of %d", stat.Uid, uid))
+	}
+	return problems
+}
+
+// openNoFollow opens the file for reading, failing if the path is a symlink.
+func openNoFollow(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+}
@@ -0,0 +1,85 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package fallback
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirectoryProvider_PermissionPolicy(t *testing.T) {
+	dir := t.TempDir()
+	path := writePasswordFile(t, dir, "password\n")
+	assert.NoError(t, os.Chmod(path, 0644))
+
+	for _, policy := range []PermissionPolicy{PermissionWarn, PermissionOff} {
+		password, err := NewDirectoryProvider(dir).WithPermissionPolicy(policy).FallbackPassword(newTestRequest(t))
+		assert.NoError(t, err, policy.String())
+		assert.Equal(t, "password", password.Value, policy.String())
+	}
+	_, err := NewDirectoryProvider(dir).WithPermissionPolicy(PermissionStrict).FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "mode 0644")
+
+	assert.NoError(t, os.Chmod(path, 0600))
+	password, err := NewDirectoryProvider(dir).WithPermissionPolicy(PermissionStrict).FallbackPassword(
+		newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+}
+
+func TestDirectoryProvider_PermissionPolicyRejectsSymlinks(t *testing.T) {
+	target := writePasswordFile(t, t.TempDir(), "password\n")
+	dir := t.TempDir()
+	assert.NoError(t, os.Symlink(target, filepath.Join(dir, filepath.Base(target))))
+
+	strict := NewDirectoryProvider(dir).WithPermissionPolicy(PermissionStrict)
+	_, err := strict.FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "it is a symlink")
+	password, err := NewDirectoryProvider(dir).WithPermissionPolicy(PermissionOff).FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+
+	link := filepath.Join(t.TempDir(), "input")
+	assert.NoError(t, os.Symlink(filepath.Dir(target), link))
+	_, err = NewDirectoryProvider(link).WithPermissionPolicy(PermissionStrict).FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "its directory is a symlink")
+}
+
+func TestDirectoryProvider_PermissionPolicyRejectsOtherOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of a file requires root")
+	}
+	dir := t.TempDir()
+	path := writePasswordFile(t, dir, "password\n")
+	assert.NoError(t, os.Chown(path, 65534, 65534))
+
+	_, err := NewDirectoryProvider(dir).WithPermissionPolicy(PermissionStrict).FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "owned by uid 65534")
+}
+
+func TestOpenPasswordFile_ReadsCheckedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writePasswordFile(t, dir, "password\n")
+
+	file, problems, err := openPasswordFile(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	assert.Empty(t, problems)
+
+	// A file swapped in after the checks is not read.
+	swapped := filepath.Join(dir, "swapped")
+	assert.NoError(t, ioutil.WriteFile(swapped, []byte("swapped\n"), 0644))
+	assert.NoError(t, os.Rename(swapped, path))
+	data, err := ioutil.ReadAll(file)
+	assert.NoError(t, err)
+	assert.Equal(t, "password\n", string(data))
+}
@@ -0,0 +1,185 @@
+package fallback
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	tchttp "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/http"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+)
+
+const (
+	ssmService        = "ssm"
+	ssmVersion        = "2019-09-23"
+	ssmGetSecretValue = "GetSecretValue"
+	// DefaultSSMTTL is how long an SSMProvider caches a secret if its TTL is zero.
+	DefaultSSMTTL = 5 * time.Minute
+	// DefaultSSMVersionId is the version of the secret read by an SSMProvider if its VersionId is empty.
+	DefaultSSMVersionId = "SSM_Current"
+)
+
+// SSMConfig represents the configuration of an SSMProvider.
+type SSMConfig struct {
+	// Credential is the credential of the SSM requests.
+	Credential *common.Credential
+	// Region is the region of the secret.
+	Region string
+	// SecretName is the name of the secret which holds the password of every token request.
+	SecretName string
+	// SecretNameFunc returns the name of the secret which holds the password of a token request, or an empty
+	// string if it has none. It takes precedence over SecretName, if not nil.
+	SecretNameFunc func(request *model.GenerateAuthenticationTokenRequest) string
+	// VersionId is the version of the secret, DefaultSSMVersionId if empty.
+	VersionId string
+	// JSONField is the field holding the password if the secret is a JSON object, such as "Password". The whole
+	// secret is the password if empty.
+	JSONField string
+	// TTL is how long a secret is cached before it is read again, DefaultSSMTTL if zero.
+	TTL time.Duration
+	// ClientProfile is the profile of the SSM client, whose HttpProfile.Endpoint overrides the SSM endpoint.
+	// The default profile is used if nil.
+	ClientProfile *profile.ClientProfile
+	// Clock is the time source of the TTL, clock.System if nil.
+	Clock clock.Clock
+}
+
+// SSMProvider reads the password of a token request from a secret of Tencent Cloud Secrets Manager with the
+// GetSecretValue API. The secret is cached for the TTL, and the cached value is still served after the TTL if the
+// secret cannot be read again. The concurrent reads of a secret are sent once.
+type SSMProvider struct {
+	config SSMConfig
+	client *common.Client
+
+	flight  secretFlight
+	mu      sync.Mutex
+	secrets map[string]*cachedSecret
+}
+
+// cachedSecret is a password read from SSM and the time it was read.
+type cachedSecret struct {
+	password *model.FallbackPassword
+	readAt   time.Time
+}
+
+// NewSSMProvider creates a new SSMProvider with the provided configuration.
+func NewSSMProvider(config SSMConfig) (*SSMProvider, error) {
+	if config.Credential == nil || config.Credential.SecretId == "" || config.Credential.SecretKey == "" {
+		return nil, fmt.Errorf("the SSM credential is invalid")
+	}
+	if config.Region == "" {
+		return nil, fmt.Errorf("the SSM region is invalid")
+	}
+	if config.SecretName == "" && config.SecretNameFunc == nil {
+		return nil, fmt.Errorf("the SSM secret name is invalid")
+	}
+	if config.TTL < 0 {
+		return nil, fmt.Errorf("the SSM TTL is invalid")
+	}
+	if config.VersionId == "" {
+		config.VersionId = DefaultSSMVersionId
+	}
+	if config.TTL == 0 {
+		config.TTL = DefaultSSMTTL
+	}
+	if config.ClientProfile == nil {
+		config.ClientProfile = profile.NewClientProfile()
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
+
+	return &SSMProvider{
+		config:  config,
+		client:  common.NewCommonClient(config.Credential, config.Region, config.ClientProfile),
+		secrets: map[string]*cachedSecret{},
+	}, nil
+}
+
+// FallbackPassword returns the password held by the secret of the token request. It returns nil if the token
+// request has no secret.
+func (p *SSMProvider) FallbackPassword(
+	request *model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+	secretName := p.config.SecretName
+	if p.config.SecretNameFunc != nil {
+		secretName = p.config.SecretNameFunc(request)
+	}
+	if secretName == "" {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	cached := p.secrets[secretName]
+	p.mu.Unlock()
+	if cached != nil && p.config.Clock.Now().Sub(cached.readAt) < p.config.TTL {
+		return cached.password, nil
+	}
+
+	password, err := p.flight.do(secretName, func() (*model.FallbackPassword, error) {
+		readAt := p.config.Clock.Now()
+		password, err := p.getSecretValue(secretName)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		p.secrets[secretName] = &cachedSecret{password: password, readAt: readAt}
+		p.mu.Unlock()
+		return password, nil
+	})
+	if err != nil {
+		if cached != nil {
+			logging.Warnf("Failed to read the SSM secret %s, serving the cached value, error: %v", secretName, err)
+			return cached.password, nil
+		}
+		return nil, err
+	}
+	return password, nil
+}
+
+// getSecretValue reads the password held by the secret from SSM.
+func (p *SSMProvider) getSecretValue(secretName string) (*model.FallbackPassword, error) {
+	request := tchttp.NewCommonRequest(ssmService, ssmVersion, ssmGetSecretValue)
+	if err := request.SetActionParameters(map[string]interface{}{
+		"SecretName": secretName,
+		"VersionId":  p.config.VersionId,
+	}); err != nil {
+		return nil, err
+	}
+	response := tchttp.NewCommonResponse()
+	if err := p.client.Send(request, response); err != nil {
+		return nil, fmt.Errorf("failed to get the value of the SSM secret %s: %w", secretName, err)
+	}
+
+	var body struct {
+		Response struct {
+			SecretString string `json:"SecretString"`
+			RequestId    string `json:"RequestId"`
+		} `json:"Response"`
+	}
+	if err := json.Unmarshal(response.GetBody(), &body); err != nil {
+		return nil, fmt.Errorf("failed to parse the value of the SSM secret %s: %w", secretName, err)
+	}
+
+	value := body.Response.SecretString
+	if p.config.JSONField != "" {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(value), &fields); err != nil {
+			return nil, fmt.Errorf("the SSM secret %s is not a JSON object", secretName)
+		}
+		field, _ := fields[p.config.JSONField].(string)
+		value = field
+	}
+	if value == "" {
+		return nil, fmt.Errorf("the SSM secret %s holds no password, requestId: %s", secretName,
+			body.Response.RequestId)
+	}
+
+	return &model.FallbackPassword{
+		Value:  value,
+		Source: fmt.Sprintf("SSM secret %s version %s", secretName, p.config.VersionId),
+	}, nil
+}
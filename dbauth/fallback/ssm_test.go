@@ -0,0 +1,234 @@
+package fallback
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+)
+
+// ssmServer is a stand-in for the GetSecretValue API of SSM.
+type ssmServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	secrets  map[string]string
+	requests []map[string]string
+	fail     bool
+	gates    map[string]chan struct{}
+}
+
+func newSSMServer(secrets map[string]string) *ssmServer {
+	s := &ssmServer{secrets: secrets}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&params)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, params)
+		secret, ok := s.secrets[params["SecretName"]+"/"+params["VersionId"]]
+		fail := s.fail
+		gate := s.gates[params["SecretName"]]
+		s.mu.Unlock()
+		if gate != nil {
+			<-gate
+		}
+
+		response := map[string]interface{}{"RequestId": "ssm-request"}
+		switch {
+		case fail:
+			response["Error"] = map[string]string{"Code": "InternalError", "Message": "unavailable"}
+		case r.Header.Get("X-TC-Action") != "GetSecretValue" || r.Header.Get("X-TC-Version") != "2019-09-23":
+			response["Error"] = map[string]string{"Code": "InvalidAction", "Message": "invalid action"}
+		case !ok:
+			response["Error"] = map[string]string{"Code": "ResourceNotFound", "Message": "no such secret"}
+		default:
+			response["SecretName"] = params["SecretName"]
+			response["VersionId"] = params["VersionId"]
+			response["SecretString"] = secret
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Response": response})
+	}))
+	return s
+}
+
+func (s *ssmServer) setFail(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+// block holds the requests of the secret until the returned channel is closed.
+func (s *ssmServer) block(secretName string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gate := make(chan struct{})
+	if s.gates == nil {
+		s.gates = map[string]chan struct{}{}
+	}
+	s.gates[secretName] = gate
+	return gate
+}
+
+func (s *ssmServer) requestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+func (s *ssmServer) config(clock *dbauthtest.FakeClock) SSMConfig {
+	clientProfile := profile.NewClientProfile()
+	clientProfile.HttpProfile.Scheme = "HTTP"
+	clientProfile.HttpProfile.Endpoint = strings.TrimPrefix(s.URL, "http://")
+	return SSMConfig{
+		Credential:    common.NewCredential("secretId", "secretKey"),
+		Region:        "ap-guangzhou",
+		SecretName:    "db-password",
+		ClientProfile: clientProfile,
+		Clock:         clock,
+	}
+}
+
+func TestSSMProvider_ReadsSecret(t *testing.T) {
+	server := newSSMServer(map[string]string{"db-password/SSM_Current": "password"})
+	defer server.Close()
+	provider, err := NewSSMProvider(server.config(dbauthtest.NewFakeClock(time.Now())))
+	assert.NoError(t, err)
+
+	password, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	assert.Equal(t, "SSM secret db-password version SSM_Current", password.Source)
+}
+
+func TestSSMProvider_JSONFieldAndVersion(t *testing.T) {
+	server := newSSMServer(map[string]string{"camtest/v2": `{"UserName":"camtest","Password":"password"}`})
+	defer server.Close()
+	config := server.config(dbauthtest.NewFakeClock(time.Now()))
+	config.SecretNameFunc = func(request *model.GenerateAuthenticationTokenRequest) string {
+		return request.UserName()
+	}
+	config.VersionId = "v2"
+	config.JSONField = "Password"
+	provider, err := NewSSMProvider(config)
+	assert.NoError(t, err)
+
+	password, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+}
+
+func TestSSMProvider_CachesForTTL(t *testing.T) {
+	server := newSSMServer(map[string]string{"db-password/SSM_Current": "password"})
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	config := server.config(fakeClock)
+	config.TTL = time.Minute
+	provider, err := NewSSMProvider(config)
+	assert.NoError(t, err)
+
+	_, err = provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	fakeClock.Advance(59 * time.Second)
+	_, err = provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, server.requestCount())
+
+	fakeClock.Advance(time.Second)
+	_, err = provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, server.requestCount())
+}
+
+func TestSSMProvider_ReadsWithoutHoldingTheLock(t *testing.T) {
+	server := newSSMServer(map[string]string{
+		"cdb-123456/SSM_Current": "password",
+		"cdb-654321/SSM_Current": "other",
+	})
+	defer server.Close()
+	gate := server.block("cdb-123456")
+	config := server.config(dbauthtest.NewFakeClock(time.Now()))
+	config.SecretNameFunc = func(request *model.GenerateAuthenticationTokenRequest) string {
+		return request.InstanceId()
+	}
+	provider, err := NewSSMProvider(config)
+	assert.NoError(t, err)
+
+	values := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			password, err := provider.FallbackPassword(newTestRequest(t))
+			assert.NoError(t, err)
+			values <- password.Value
+		}()
+	}
+	// The concurrent reads of a secret are sent once.
+	assert.Eventually(t, func() bool {
+		return provider.flight.waiters("cdb-123456") == 2
+	}, time.Second, time.Millisecond)
+
+	// Another secret is read while the first one is in flight.
+	otherRequest, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-654321", "camtest",
+		common.NewCredential("secretId", "secretKey"), nil)
+	assert.NoError(t, err)
+	password, err := provider.FallbackPassword(otherRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", password.Value)
+
+	close(gate)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "password", <-values)
+	}
+	assert.Equal(t, 2, server.requestCount())
+}
+
+func TestSSMProvider_ServesCachedValueOnError(t *testing.T) {
+	server := newSSMServer(map[string]string{"db-password/SSM_Current": "password"})
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	provider, err := NewSSMProvider(server.config(fakeClock))
+	assert.NoError(t, err)
+
+	server.setFail(true)
+	_, err = provider.FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+
+	server.setFail(false)
+	_, err = provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+
+	server.setFail(true)
+	fakeClock.Advance(DefaultSSMTTL)
+	password, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	assert.Equal(t, 3, server.requestCount())
+}
+
+func TestSSMProvider_MissingSecret(t *testing.T) {
+	server := newSSMServer(map[string]string{})
+	defer server.Close()
+	provider, err := NewSSMProvider(server.config(dbauthtest.NewFakeClock(time.Now())))
+	assert.NoError(t, err)
+
+	_, err = provider.FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+}
+
+func TestNewSSMProvider_InvalidConfig(t *testing.T) {
+	_, err := NewSSMProvider(SSMConfig{Region: "ap-guangzhou", SecretName: "db-password"})
+	assert.Error(t, err)
+	_, err = NewSSMProvider(SSMConfig{Credential: common.NewCredential("secretId", "secretKey"),
+		Region: "ap-guangzhou"})
+	assert.Error(t, err)
+}
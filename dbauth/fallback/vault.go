@@ -0,0 +1,433 @@
+package fallback
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+const (
+	// DefaultVaultMount is the mount of the KV v2 secrets engine read by a VaultProvider if its Mount is empty.
+	DefaultVaultMount = "secret"
+	// DefaultVaultField is the field of the secret read by a VaultProvider if its Field is empty.
+	DefaultVaultField = "password"
+	// DefaultVaultAppRoleMount is the mount of the AppRole auth method if the AppRoleMount is empty.
+	DefaultVaultAppRoleMount = "approle"
+	// DefaultVaultTTL is how long a VaultProvider caches a secret if its TTL is zero.
+	DefaultVaultTTL = 5 * time.Minute
+
+	minVaultRenewalDelay = time.Second
+	vaultRetryDelay      = 10 * time.Second
+	vaultRequestTimeout  = 10 * time.Second
+)
+
+// VaultConfig represents the configuration of a VaultProvider. Either Token or RoleId and SecretId must be set.
+type VaultConfig struct {
+	// Address is the address of the Vault server, such as https://vault.example.com:8200.
+	Address string
+	// Namespace is the Vault Enterprise namespace of the requests, if not empty.
+	Namespace string
+	// Mount is the mount of the KV v2 secrets engine, DefaultVaultMount if empty.
+	Mount string
+	// Path is the path of the secret which holds the password of every token request, relative to the mount.
+	Path string
+	// PathFunc returns the path of the secret which holds the password of a token request, or an empty string if
+	// it has none. It takes precedence over Path, if not nil.
+	PathFunc func(request *model.GenerateAuthenticationTokenRequest) string
+	// Field is the field of the secret holding the password, DefaultVaultField if empty.
+	Field string
+	// Token is the Vault token of the token auth method.
+	Token string
+	// RoleId is the role id of the AppRole auth method.
+	RoleId string
+	// SecretId is the secret id of the AppRole auth method.
+	SecretId string
+	// AppRoleMount is the mount of the AppRole auth method, DefaultVaultAppRoleMount if empty.
+	AppRoleMount string
+	// TTL is how long a secret is cached before it is read again, DefaultVaultTTL if zero.
+	TTL time.Duration
+	// HTTPClient sends the Vault requests, a client with a 10 seconds timeout if nil.
+	HTTPClient *http.Client
+	// Clock is the time source of the TTL and of the token renewal, clock.System if nil.
+	Clock clock.Clock
+}
+
+// VaultProvider reads the password of a token request from a secret of the KV v2 secrets engine of HashiCorp
+// Vault. It logs in with AppRole or uses the provided token, and renews the lease of the token in the background
+// at half of its duration, logging in again with AppRole when the token cannot be renewed. The secret is cached
+// for the TTL, and the cached value is still served after the TTL if the secret cannot be read again. The
+// concurrent reads of a secret are sent once.
+// It must be closed with Close to stop the renewal.
+type VaultProvider struct {
+	config VaultConfig
+	flight secretFlight
+	// authMu serializes the logins, lookups and renewals of the token, so that concurrent reads authenticate once.
+	authMu sync.Mutex
+
+	mu           sync.Mutex
+	token        string
+	renewable    bool
+	leaseExpiry  time.Time
+	renewalTimer clock.Timer
+	closed       bool
+	secrets      map[string]*cachedSecret
+}
+
+// vaultAuth is the auth block of the responses of the Vault login and renewal APIs.
+type vaultAuth struct {
+	ClientToken   string `json:"client_token"`
+	LeaseDuration int64  `json:"lease_duration"`
+	Renewable     bool   `json:"renewable"`
+}
+
+// NewVaultProvider creates a new VaultProvider with the provided configuration, and authenticates in the
+// background so that the lease of the token is renewed from the start.
+func NewVaultProvider(config VaultConfig) (*VaultProvider, error) {
+	if _, err := url.Parse(config.Address); err != nil || config.Address == "" {
+		return nil, fmt.Errorf("the Vault address is invalid")
+	}
+	if config.Path == "" && config.PathFunc == nil {
+		return nil, fmt.Errorf("the Vault secret path is invalid")
+	}
+	if config.Token == "" && (config.RoleId == "" || config.SecretId == "") {
+		return nil, fmt.Errorf("the Vault token or the AppRole role id and secret id are required")
+	}
+	if config.TTL < 0 {
+		return nil, fmt.Errorf("the Vault TTL is invalid")
+	}
+	config.Address = strings.TrimSuffix(config.Address, "/")
+	if config.Mount == "" {
+		config.Mount = DefaultVaultMount
+	}
+	if config.Field == "" {
+		config.Field = DefaultVaultField
+	}
+	if config.AppRoleMount == "" {
+		config.AppRoleMount = DefaultVaultAppRoleMount
+	}
+	if config.TTL == 0 {
+		config.TTL = DefaultVaultTTL
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: vaultRequestTimeout}
+	}
+	if config.Clock == nil {
+		config.Clock = clock.System
+	}
+
+	p := &VaultProvider{config: config, secrets: map[string]*cachedSecret{}}
+	p.renewalTimer = config.Clock.AfterFunc(0, p.renew)
+	return p, nil
+}
+
+// FallbackPassword returns the password held by the secret of the token request. It returns nil if the token
+// request has no secret.
+func (p *VaultProvider) FallbackPassword(
+	request *model.GenerateAuthenticationTokenRequest) (*model.FallbackPassword, error) {
+	path := p.config.Path
+	if p.config.PathFunc != nil {
+		path = p.config.PathFunc(request)
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	p.mu.Lock()
+	cached := p.secrets[path]
+	p.mu.Unlock()
+	if cached != nil && p.config.Clock.Now().Sub(cached.readAt) < p.config.TTL {
+		return cached.password, nil
+	}
+
+	password, err := p.flight.do(path, func() (*model.FallbackPassword, error) {
+		readAt := p.config.Clock.Now()
+		password, err := p.readSecret(path)
+		if err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		p.secrets[path] = &cachedSecret{password: password, readAt: readAt}
+		p.mu.Unlock()
+		return password, nil
+	})
+	if err != nil {
+		if cached != nil {
+			logging.Warnf("Failed to read the Vault secret %s, serving the cached value, error: %v", path, err)
+			return cached.password, nil
+		}
+		return nil, err
+	}
+	return password, nil
+}
+
+// Close stops the renewal of the token.
+func (p *VaultProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.renewalTimer != nil {
+		p.renewalTimer.Stop()
+	}
+	return nil
+}
+
+// readSecret reads the password held by the secret from Vault.
+func (p *VaultProvider) readSecret(path string) (*model.FallbackPassword, error) {
+	token, err := p.ensureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	secretPath := "/v1/" + p.config.Mount + "/data/" + strings.TrimPrefix(path, "/")
+	var response struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	status, err := p.do(http.MethodGet, secretPath, token, nil, &response)
+	if status == http.StatusForbidden && p.isAppRole() {
+		// The token may have been revoked, so log in again once.
+		p.discardToken(token)
+		if token, err = p.ensureToken(); err == nil {
+			_, err = p.do(http.MethodGet, secretPath, token, nil, &response)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Vault secret %s: %w", path, err)
+	}
+
+	value, _ := response.Data.Data[p.config.Field].(string)
+	if value == "" {
+		return nil, fmt.Errorf("the Vault secret %s has no field %s", path, p.config.Field)
+	}
+	return &model.FallbackPassword{
+		Value:  value,
+		Source: fmt.Sprintf("Vault secret %s/%s field %s", p.config.Mount, path, p.config.Field),
+	}, nil
+}
+
+// renew renews the token and schedules the next renewal.
+func (p *VaultProvider) renew() {
+	if p.isClosed() {
+		return
+	}
+
+	err := p.renewToken()
+	if err != nil {
+		logging.Errorf("Failed to renew the Vault token, error: %v", err)
+	}
+	delay, ok := p.nextRenewal(err)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ok && !p.closed {
+		p.renewalTimer = p.config.Clock.AfterFunc(delay, p.renew)
+	}
+}
+
+// renewToken renews the lease of the token if it is renewable, or logs in again with AppRole otherwise. It
+// authenticates first if there is no token yet.
+func (p *VaultProvider) renewToken() error {
+	p.authMu.Lock()
+	defer p.authMu.Unlock()
+
+	p.mu.Lock()
+	token, renewable := p.token, p.renewable
+	p.mu.Unlock()
+	if token == "" {
+		_, err := p.authenticate()
+		return err
+	}
+
+	if renewable {
+		var response struct {
+			Auth vaultAuth `json:"auth"`
+		}
+		_, err := p.do(http.MethodPost, "/v1/auth/token/renew-self", token, map[string]interface{}{}, &response)
+		if err == nil {
+			p.setLease(response.Auth)
+			logging.Infof("Renewed the Vault token for %ds", response.Auth.LeaseDuration)
+			return nil
+		}
+		if !p.isAppRole() {
+			return err
+		}
+		logging.Warnf("Failed to renew the Vault token, logging in again, error: %v", err)
+	}
+	if p.isAppRole() {
+		_, err := p.authenticate()
+		return err
+	}
+	return nil
+}
+
+// nextRenewal returns the delay of the next renewal: half of the remaining lease of the token, or a retry delay
+// after an error. It returns false if the token does not need to be renewed.
+func (p *VaultProvider) nextRenewal(err error) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err != nil {
+		return vaultRetryDelay, p.isAppRole() || p.leaseExpiry.IsZero() || p.renewable
+	}
+	if p.leaseExpiry.IsZero() || (!p.renewable && !p.isAppRole()) {
+		return 0, false
+	}
+	delay := p.leaseExpiry.Sub(p.config.Clock.Now()) / 2
+	if delay < minVaultRenewalDelay {
+		delay = minVaultRenewalDelay
+	}
+	return delay, true
+}
+
+// ensureToken returns the token, logging in with AppRole, or looking the provided token up, if there is no valid
+// token.
+func (p *VaultProvider) ensureToken() (string, error) {
+	p.authMu.Lock()
+	defer p.authMu.Unlock()
+
+	p.mu.Lock()
+	token := p.token
+	valid := token != "" && (p.leaseExpiry.IsZero() || p.config.Clock.Now().Before(p.leaseExpiry))
+	p.mu.Unlock()
+	if valid {
+		return token, nil
+	}
+	return p.authenticate()
+}
+
+// authenticate logs in with AppRole, or looks the provided token up, and returns the token. It must be called
+// with authMu held.
+func (p *VaultProvider) authenticate() (string, error) {
+	if p.isAppRole() {
+		p.mu.Lock()
+		p.token = ""
+		p.mu.Unlock()
+		var response struct {
+			Auth vaultAuth `json:"auth"`
+		}
+		if _, err := p.do(http.MethodPost, "/v1/auth/"+p.config.AppRoleMount+"/login", "", map[string]interface{}{
+			"role_id":   p.config.RoleId,
+			"secret_id": p.config.SecretId,
+		}, &response); err != nil {
+			return "", fmt.Errorf("failed to log in to Vault with AppRole: %w", err)
+		}
+		if response.Auth.ClientToken == "" {
+			return "", fmt.Errorf("the Vault AppRole login returned no token")
+		}
+		p.setLease(response.Auth)
+		return response.Auth.ClientToken, nil
+	}
+
+	if p.currentToken() != "" {
+		return "", fmt.Errorf("the Vault token has expired")
+	}
+	var response struct {
+		Data struct {
+			TTL       int64 `json:"ttl"`
+			Renewable bool  `json:"renewable"`
+		} `json:"data"`
+	}
+	if _, err := p.do(http.MethodGet, "/v1/auth/token/lookup-self", p.config.Token, nil, &response); err != nil {
+		return "", fmt.Errorf("failed to look the Vault token up: %w", err)
+	}
+	p.setLease(vaultAuth{ClientToken: p.config.Token, LeaseDuration: response.Data.TTL,
+		Renewable: response.Data.Renewable})
+	return p.config.Token, nil
+}
+
+// discardToken discards the token, unless it has already been replaced.
+func (p *VaultProvider) discardToken(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.token == token {
+		p.token = ""
+	}
+}
+
+func (p *VaultProvider) currentToken() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.token
+}
+
+func (p *VaultProvider) isClosed() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.closed
+}
+
+// setLease records the lease of the token. A zero lease duration means that the token does not expire.
+func (p *VaultProvider) setLease(auth vaultAuth) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if auth.ClientToken != "" {
+		p.token = auth.ClientToken
+	}
+	p.renewable = auth.Renewable
+	p.leaseExpiry = time.Time{}
+	if auth.LeaseDuration > 0 {
+		p.leaseExpiry = p.config.Clock.Now().Add(time.Duration(auth.LeaseDuration) * time.Second)
+	}
+}
+
+func (p *VaultProvider) isAppRole() bool {
+	return p.config.RoleId != "" && p.config.SecretId != ""
+}
+
+// do sends a request to Vault with the token, if not empty, and decodes its response into result. It returns the
+// status code of the response.
+func (p *VaultProvider) do(method, path, token string, body interface{}, result interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, p.config.Address+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if p.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.config.HTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errorResponse struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(content, &errorResponse)
+		return resp.StatusCode, fmt.Errorf("the Vault server answered %s: %s", resp.Status,
+			strings.Join(errorResponse.Errors, "; "))
+	}
+	if err := json.Unmarshal(content, result); err != nil {
+		return resp.StatusCode, fmt.Errorf("failed to parse the Vault response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
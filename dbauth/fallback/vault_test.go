@@ -0,0 +1,302 @@
+package fallback
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+)
+
+// vaultServer is a stand-in for the token, AppRole and KV v2 APIs of Vault.
+type vaultServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	tokens   map[string]bool
+	secrets  map[string]map[string]interface{}
+	logins   int
+	renewals int
+	reads    int
+	down     bool
+	gates    map[string]chan struct{}
+}
+
+func newVaultServer(secrets map[string]map[string]interface{}) *vaultServer {
+	s := &vaultServer{tokens: map[string]bool{"static-token": true}, secrets: secrets}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *vaultServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	gate := s.gates[r.URL.Path]
+	s.mu.Unlock()
+	if gate != nil {
+		<-gate
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.down {
+		writeVaultResponse(w, http.StatusServiceUnavailable, map[string]interface{}{"errors": []string{"sealed"}})
+		return
+	}
+	if r.Method == http.MethodPost && r.URL.Path == "/v1/auth/approle/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			writeVaultResponse(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid"}})
+			return
+		}
+		s.logins++
+		token := fmt.Sprintf("approle-token-%d", s.logins)
+		s.tokens[token] = true
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{"auth": map[string]interface{}{
+			"client_token": token, "lease_duration": 60, "renewable": true}})
+		return
+	}
+
+	token := r.Header.Get("X-Vault-Token")
+	if !s.tokens[token] {
+		writeVaultResponse(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/v1/auth/token/lookup-self":
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"ttl": 60, "renewable": true}})
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/auth/token/renew-self":
+		s.renewals++
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{"auth": map[string]interface{}{
+			"client_token": token, "lease_duration": 60, "renewable": true}})
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/secret/data/"):
+		s.reads++
+		data, ok := s.secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+		if !ok {
+			writeVaultResponse(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+		writeVaultResponse(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"data": data}})
+	default:
+		writeVaultResponse(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func writeVaultResponse(w http.ResponseWriter, status int, body map[string]interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (s *vaultServer) revokeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens = map[string]bool{}
+}
+
+func (s *vaultServer) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+// block holds the requests of the path until the returned channel is closed.
+func (s *vaultServer) block(path string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gate := make(chan struct{})
+	if s.gates == nil {
+		s.gates = map[string]chan struct{}{}
+	}
+	s.gates[path] = gate
+	return gate
+}
+
+func (s *vaultServer) counts() (logins, renewals, reads int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins, s.renewals, s.reads
+}
+
+func newTestVaultProvider(t *testing.T, server *vaultServer, fakeClock *dbauthtest.FakeClock,
+	configure func(config *VaultConfig)) *VaultProvider {
+	config := VaultConfig{Address: server.URL, Path: "db/camtest", Token: "static-token", Clock: fakeClock}
+	if configure != nil {
+		configure(&config)
+	}
+	provider, err := NewVaultProvider(config)
+	assert.NoError(t, err)
+	// Run the initial authentication.
+	fakeClock.Advance(0)
+	return provider
+}
+
+func TestVaultProvider_TokenAuth(t *testing.T) {
+	server := newVaultServer(map[string]map[string]interface{}{"db/camtest": {"password": "password"}})
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	provider := newTestVaultProvider(t, server, fakeClock, nil)
+	defer provider.Close()
+
+	password, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	assert.Equal(t, "Vault secret secret/db/camtest field password", password.Source)
+
+	// The token is renewed at half of its lease, and again at half of the renewed lease.
+	fakeClock.Advance(30 * time.Second)
+	_, renewals, _ := server.counts()
+	assert.Equal(t, 1, renewals)
+	fakeClock.Advance(30 * time.Second)
+	_, renewals, _ = server.counts()
+	assert.Equal(t, 2, renewals)
+}
+
+func TestVaultProvider_AppRoleAuthAndField(t *testing.T) {
+	server := newVaultServer(map[string]map[string]interface{}{"db/camtest": {"pw": "password"}})
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	provider := newTestVaultProvider(t, server, fakeClock, func(config *VaultConfig) {
+		config.Token = ""
+		config.RoleId = "role"
+		config.SecretId = "secret"
+		config.Field = "pw"
+		config.TTL = time.Second
+	})
+	defer provider.Close()
+
+	password, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+
+	// A revoked token is replaced by logging in again.
+	server.revokeAll()
+	fakeClock.Advance(time.Second)
+	password, err = provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+	logins, _, _ := server.counts()
+	assert.Equal(t, 2, logins)
+}
+
+func TestVaultProvider_CachesValue(t *testing.T) {
+	server := newVaultServer(map[string]map[string]interface{}{"db/camtest": {"password": "password"}})
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	provider := newTestVaultProvider(t, server, fakeClock, func(config *VaultConfig) {
+		config.TTL = 10 * time.Second
+	})
+	defer provider.Close()
+
+	_, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	_, err = provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	_, _, reads := server.counts()
+	assert.Equal(t, 1, reads)
+
+	// The cached value is served when Vault is down after the TTL.
+	server.setDown(true)
+	fakeClock.Advance(10 * time.Second)
+	password, err := provider.FallbackPassword(newTestRequest(t))
+	assert.NoError(t, err)
+	assert.Equal(t, "password", password.Value)
+}
+
+func TestVaultProvider_ReadsWithoutHoldingTheLock(t *testing.T) {
+	server := newVaultServer(map[string]map[string]interface{}{
+		"db/cdb-123456": {"password": "password"},
+		"db/cdb-654321": {"password": "other"},
+	})
+	defer server.Close()
+	provider := newTestVaultProvider(t, server, dbauthtest.NewFakeClock(time.Now()), func(config *VaultConfig) {
+		config.PathFunc = func(request *model.GenerateAuthenticationTokenRequest) string {
+			return "db/" + request.InstanceId()
+		}
+	})
+	defer provider.Close()
+	gate := server.block("/v1/secret/data/db/cdb-123456")
+
+	values := make(chan string, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			password, err := provider.FallbackPassword(newTestRequest(t))
+			assert.NoError(t, err)
+			values <- password.Value
+		}()
+	}
+	// The concurrent reads of a secret are sent once.
+	assert.Eventually(t, func() bool {
+		return provider.flight.waiters("db/cdb-123456") == 2
+	}, time.Second, time.Millisecond)
+
+	// Another secret is read while the first one is in flight.
+	otherRequest, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-654321", "camtest",
+		common.NewCredential("secretId", "secretKey"), nil)
+	assert.NoError(t, err)
+	password, err := provider.FallbackPassword(otherRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "other", password.Value)
+
+	close(gate)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, "password", <-values)
+	}
+	_, _, reads := server.counts()
+	assert.Equal(t, 2, reads)
+}
+
+func TestVaultProvider_MissingField(t *testing.T) {
+	server := newVaultServer(map[string]map[string]interface{}{"db/camtest": {"other": "value"}})
+	defer server.Close()
+	provider := newTestVaultProvider(t, server, dbauthtest.NewFakeClock(time.Now()), nil)
+	defer provider.Close()
+
+	_, err := provider.FallbackPassword(newTestRequest(t))
+	assert.Error(t, err)
+}
+
+func TestVaultProvider_CloseStopsRenewal(t *testing.T) {
+	server := newVaultServer(map[string]map[string]interface{}{})
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	provider := newTestVaultProvider(t, server, fakeClock, nil)
+
+	provider.Close()
+	fakeClock.Advance(time.Hour)
+	_, renewals, _ := server.counts()
+	assert.Equal(t, 0, renewals)
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+}
+
+func TestChain_CloseStopsVaultRenewal(t *testing.T) {
+	server := newVaultServer(map[string]map[string]interface{}{})
+	defer server.Close()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	provider := newTestVaultProvider(t, server, fakeClock, nil)
+
+	assert.NoError(t, NewChain(NewChain(provider)).Close())
+	fakeClock.Advance(time.Hour)
+	_, renewals, _ := server.counts()
+	assert.Equal(t, 0, renewals)
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+}
+
+func TestNewVaultProvider_InvalidConfig(t *testing.T) {
+	_, err := NewVaultProvider(VaultConfig{Path: "db/camtest", Token: "token"})
+	assert.Error(t, err)
+	_, err = NewVaultProvider(VaultConfig{Address: "http://127.0.0.1:8200", Token: "token"})
+	assert.Error(t, err)
+	_, err = NewVaultProvider(VaultConfig{Address: "http://127.0.0.1:8200", Path: "db/camtest", RoleId: "role"})
+	assert.Error(t, err)
+}
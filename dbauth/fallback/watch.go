@@ -0,0 +1,132 @@
+package fallback
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+// passwordFileSuffix is the suffix of the password files of a DirectoryProvider.
+const passwordFileSuffix = ".pwd"
+
+// fileState identifies a version of a password file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+	id      uint64
+}
+
+// directoryWatch polls the password files of a DirectoryProvider.
+type directoryWatch struct {
+	provider *DirectoryProvider
+	interval time.Duration
+	clock    clock.Clock
+	onChange func(source string)
+
+	mu      sync.Mutex
+	files   map[string]fileState
+	timer   clock.Timer
+	stopped bool
+}
+
+// WatchFallback polls the directory of the provider at the interval, and calls onChange with the path of every
+// password file which was created, modified, replaced or removed since the previous poll. The files are compared by
+// modification time, size and inode. It returns the function which stops the polling.
+func (p *DirectoryProvider) WatchFallback(interval time.Duration, watchClock clock.Clock,
+	onChange func(source string)) (stop func()) {
+	watch := &directoryWatch{provider: p, interval: interval, clock: watchClock, onChange: onChange}
+	watch.files = watch.scan()
+	watch.timer = watchClock.AfterFunc(interval, watch.poll)
+	return watch.stop
+}
+
+// poll compares the password files with the previous poll and schedules the next one.
+func (w *directoryWatch) poll() {
+	files := w.scan()
+
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return
+	}
+	var changed []string
+	for path, state := range files {
+		if previous, ok := w.files[path]; !ok || previous != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range w.files {
+		if _, ok := files[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	w.files = files
+	w.timer = w.clock.AfterFunc(w.interval, w.poll)
+	w.mu.Unlock()
+
+	sort.Strings(changed)
+	for _, path := range changed {
+		w.onChange(path)
+	}
+}
+
+// scan returns the state of every password file of the directory. A directory which cannot be read has no file.
+func (w *directoryWatch) scan() map[string]fileState {
+	files := map[string]fileState{}
+	dir, err := w.provider.resolvedDir()
+	if err != nil {
+		return files
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logging.Warnf("Failed to read the fallback directory %s, error: %v", dir, err)
+		}
+		return files
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), passwordFileSuffix) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Follow the symlinks, so that a change of their target is detected.
+		fileInfo, err := os.Stat(path)
+		if err != nil || !fileInfo.Mode().IsRegular() {
+			continue
+		}
+		files[path] = fileState{modTime: fileInfo.ModTime(), size: fileInfo.Size(), id: fileID(fileInfo)}
+	}
+	return files
+}
+
+// stop stops the polling.
+func (w *directoryWatch) stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopped = true
+	w.timer.Stop()
+}
+
+// WatchFallback watches the providers of the chain which implement model.FallbackWatcher. It returns the function
+// which stops watching them.
+func (c Chain) WatchFallback(interval time.Duration, watchClock clock.Clock,
+	onChange func(source string)) (stop func()) {
+	var stops []func()
+	for _, provider := range c {
+		if watcher, ok := provider.(model.FallbackWatcher); ok {
+			stops = append(stops, watcher.WatchFallback(interval, watchClock, onChange))
+		}
+	}
+	return func() {
+		for _, stop := range stops {
+			stop()
+		}
+	}
+}
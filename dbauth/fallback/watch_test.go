@@ -0,0 +1,78 @@
+package fallback
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+)
+
+// changeRecorder records the sources passed to onChange.
+type changeRecorder struct {
+	mu      sync.Mutex
+	sources []string
+}
+
+func (r *changeRecorder) onChange(source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources = append(r.sources, source)
+}
+
+func (r *changeRecorder) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sources := r.sources
+	r.sources = nil
+	return sources
+}
+
+func TestDirectoryProvider_WatchFallback(t *testing.T) {
+	dir := t.TempDir()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	recorder := &changeRecorder{}
+	stop := NewDirectoryProvider(dir).WatchFallback(time.Second, fakeClock, recorder.onChange)
+
+	path := writePasswordFile(t, dir, "first\n")
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("ignored"), 0600))
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, []string{path}, recorder.take())
+
+	fakeClock.Advance(time.Second)
+	assert.Empty(t, recorder.take())
+
+	writePasswordFile(t, dir, "second-password\n")
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, []string{path}, recorder.take())
+
+	assert.NoError(t, os.Remove(path))
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, []string{path}, recorder.take())
+
+	stop()
+	writePasswordFile(t, dir, "fourth\n")
+	fakeClock.Advance(time.Second)
+	assert.Empty(t, recorder.take())
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+}
+
+func TestChain_WatchFallback(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	recorder := &changeRecorder{}
+	chain := NewChain(NewDirectoryProvider(first), NewEnvProvider(""), NewDirectoryProvider(second))
+	stop := chain.WatchFallback(time.Second, fakeClock, recorder.onChange)
+	defer stop()
+
+	path := writePasswordFile(t, second, "password\n")
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, []string{path}, recorder.take())
+
+	stop()
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+}
@@ -0,0 +1,34 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package fallback
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+)
+
+func TestDirectoryProvider_WatchFallbackDetectsReplacedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := writePasswordFile(t, dir, "first-password\n")
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	recorder := &changeRecorder{}
+	stop := NewDirectoryProvider(dir).WatchFallback(time.Second, fakeClock, recorder.onChange)
+	defer stop()
+
+	// A file replaced with the same size and modification time is detected by its inode.
+	fileInfo, err := os.Stat(path)
+	assert.NoError(t, err)
+	replacement := filepath.Join(dir, "replacement")
+	assert.NoError(t, ioutil.WriteFile(replacement, []byte("other-password\n"), 0600))
+	assert.NoError(t, os.Chtimes(replacement, fileInfo.ModTime(), fileInfo.ModTime()))
+	assert.NoError(t, os.Rename(replacement, path))
+	fakeClock.Advance(time.Second)
+	assert.Equal(t, []string{path}, recorder.take())
+}
@@ -0,0 +1,111 @@
+// Package breaker provides the circuit breaker of the CAM requests of a client.
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+// Breaker is a circuit breaker. It opens after threshold consecutive failures, rejects the requests for the open
+// duration, and then lets a single probe request through, half-open, which closes it if it succeeds.
+type Breaker struct {
+	threshold     int
+	openDuration  time.Duration
+	clock         clock.Clock
+	onStateChange func(from, to model.CircuitState)
+
+	mu       sync.Mutex
+	state    model.CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker creates a new closed Breaker. onStateChange, if not nil, is called after every state change, outside
+// of the lock of the breaker.
+func NewBreaker(threshold int, openDuration time.Duration, breakerClock clock.Clock,
+	onStateChange func(from, to model.CircuitState)) *Breaker {
+	return &Breaker{
+		threshold:     threshold,
+		openDuration:  openDuration,
+		clock:         breakerClock,
+		onStateChange: onStateChange,
+	}
+}
+
+// Allow checks whether a request can be sent. A request it allows must be followed by a call to Success or Failure.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	allowed, from, changed := b.allow()
+	b.mu.Unlock()
+
+	if changed {
+		b.notify(from, model.CircuitHalfOpen)
+	}
+	return allowed
+}
+
+func (b *Breaker) allow() (allowed bool, from model.CircuitState, changed bool) {
+	switch b.state {
+	case model.CircuitOpen:
+		if b.clock.Now().Sub(b.openedAt) < b.openDuration {
+			return false, b.state, false
+		}
+		b.state, b.probing = model.CircuitHalfOpen, true
+		return true, model.CircuitOpen, true
+	case model.CircuitHalfOpen:
+		if b.probing {
+			return false, b.state, false
+		}
+		b.probing = true
+		return true, b.state, false
+	default:
+		return true, b.state, false
+	}
+}
+
+// Success records a request which reached the server.
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	from := b.state
+	b.failures, b.probing = 0, false
+	b.state = model.CircuitClosed
+	b.mu.Unlock()
+
+	if from != model.CircuitClosed {
+		b.notify(from, model.CircuitClosed)
+	}
+}
+
+// Failure records a request which could not reach the server.
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	from := b.state
+	b.probing = false
+	b.failures++
+	opened := from == model.CircuitHalfOpen || (from == model.CircuitClosed && b.failures >= b.threshold)
+	if opened {
+		b.state, b.openedAt = model.CircuitOpen, b.clock.Now()
+	}
+	b.mu.Unlock()
+
+	if opened {
+		b.notify(from, model.CircuitOpen)
+	}
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() model.CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) notify(from, to model.CircuitState) {
+	if b.onStateChange != nil {
+		b.onStateChange(from, to)
+	}
+}
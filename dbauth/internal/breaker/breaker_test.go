@@ -0,0 +1,79 @@
+package breaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+type transition struct {
+	from, to model.CircuitState
+}
+
+func newTestBreaker(fakeClock *dbauthtest.FakeClock) (*Breaker, *[]transition) {
+	var transitions []transition
+	breaker := NewBreaker(2, time.Minute, fakeClock, func(from, to model.CircuitState) {
+		transitions = append(transitions, transition{from, to})
+	})
+	return breaker, &transitions
+}
+
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	breaker, transitions := newTestBreaker(dbauthtest.NewFakeClock(time.Now()))
+
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+	breaker.Success()
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, model.CircuitClosed, breaker.State())
+
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, model.CircuitOpen, breaker.State())
+	assert.False(t, breaker.Allow())
+	assert.Equal(t, []transition{{model.CircuitClosed, model.CircuitOpen}}, *transitions)
+}
+
+func TestBreaker_HalfOpenProbeCloses(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	breaker, transitions := newTestBreaker(fakeClock)
+	breaker.Failure()
+	breaker.Failure()
+
+	fakeClock.Advance(time.Minute)
+	assert.True(t, breaker.Allow())
+	assert.Equal(t, model.CircuitHalfOpen, breaker.State())
+	// A single probe is let through at a time.
+	assert.False(t, breaker.Allow())
+
+	breaker.Success()
+	assert.Equal(t, model.CircuitClosed, breaker.State())
+	assert.True(t, breaker.Allow())
+	assert.Equal(t, []transition{
+		{model.CircuitClosed, model.CircuitOpen},
+		{model.CircuitOpen, model.CircuitHalfOpen},
+		{model.CircuitHalfOpen, model.CircuitClosed},
+	}, *transitions)
+}
+
+func TestBreaker_HalfOpenProbeFailureReopens(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	breaker, _ := newTestBreaker(fakeClock)
+	breaker.Failure()
+	breaker.Failure()
+
+	fakeClock.Advance(time.Minute)
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+
+	assert.Equal(t, model.CircuitOpen, breaker.State())
+	fakeClock.Advance(59 * time.Second)
+	assert.False(t, breaker.Allow())
+	fakeClock.Advance(time.Second)
+	assert.True(t, breaker.Allow())
+}
@@ -10,6 +10,18 @@ import (
 
 const ErrorAuthFailurePrefix = "AuthFailure."
 
+const (
+	// ErrorNetwork is the error code of a CAM request which got no response.
+	ErrorNetwork = "ClientError.NetworkError"
+	// ErrorHttpStatusCode is the error code of a CAM request answered with a status other than 200.
+	ErrorHttpStatusCode = "ClientError.HttpStatusCodeError"
+	// ErrorRequestLimitExceeded is the error code, or the prefix of the error codes, of a throttled CAM request.
+	ErrorRequestLimitExceeded = "RequestLimitExceeded"
+	// ErrorCircuitOpen is the error code of a CAM request which was not sent because the circuit breaker of every
+	// endpoint was open.
+	ErrorCircuitOpen = "ClientError.CircuitBreakerError"
+)
+
 // IsUserNotificationRequired checks if the error code requires user notification.
 func IsUserNotificationRequired(err error) bool {
 	tcErr, ok := err.(*errors.TencentCloudSDKError)
@@ -27,3 +39,27 @@ func IsUserNotificationRequired(err error) bool {
 	return strings.HasPrefix(lowerErrorCode, strings.ToLower(ErrorAuthFailurePrefix)) ||
 		strings.EqualFold(lowerErrorCode, cam.RESOURCENOTFOUND_DATAFLOWAUTHCLOSE)
 }
+
+// IsNetworkError checks if the error means that the CAM endpoint could not be reached or did not answer properly,
+// so that another endpoint should be tried.
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	tcErr, ok := err.(*errors.TencentCloudSDKError)
+	if !ok {
+		return true
+	}
+	return strings.EqualFold(tcErr.Code, ErrorNetwork) || strings.EqualFold(tcErr.Code, ErrorHttpStatusCode)
+}
+
+// IsRateLimitError checks if the error means that the CAM request was throttled.
+func IsRateLimitError(err error) bool {
+	tcErr, ok := err.(*errors.TencentCloudSDKError)
+	if !ok {
+		return false
+	}
+	lowerErrorCode := strings.ToLower(tcErr.Code)
+	lowerPrefix := strings.ToLower(ErrorRequestLimitExceeded)
+	return lowerErrorCode == lowerPrefix || strings.HasPrefix(lowerErrorCode, lowerPrefix+".")
+}
@@ -1,6 +1,7 @@
 package errorcode
 
 import (
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -45,3 +46,32 @@ func TestIsUserNotificationRequired_NonTencentCloudSDKError(t *testing.T) {
 	err := &errors.TencentCloudSDKError{}
 	assert.False(t, IsUserNotificationRequired(err))
 }
+
+func TestIsNetworkError_NetworkError(t *testing.T) {
+	assert.True(t, IsNetworkError(&errors.TencentCloudSDKError{Code: "ClientError.NetworkError"}))
+	assert.True(t, IsNetworkError(&errors.TencentCloudSDKError{Code: "ClientError.HttpStatusCodeError"}))
+}
+
+func TestIsNetworkError_NonTencentCloudSDKError(t *testing.T) {
+	assert.True(t, IsNetworkError(io.EOF))
+}
+
+func TestIsNetworkError_ServiceError(t *testing.T) {
+	assert.False(t, IsNetworkError(&errors.TencentCloudSDKError{Code: "RequestLimitExceeded"}))
+	assert.False(t, IsNetworkError(&errors.TencentCloudSDKError{Code: "AuthFailure.InvalidSecretId"}))
+}
+
+func TestIsNetworkError_NilError(t *testing.T) {
+	assert.False(t, IsNetworkError(nil))
+}
+
+func TestIsRateLimitError_RequestLimitExceeded(t *testing.T) {
+	assert.True(t, IsRateLimitError(&errors.TencentCloudSDKError{Code: "RequestLimitExceeded"}))
+	assert.True(t, IsRateLimitError(&errors.TencentCloudSDKError{Code: "RequestLimitExceeded.UinLimitExceeded"}))
+}
+
+func TestIsRateLimitError_OtherError(t *testing.T) {
+	assert.False(t, IsRateLimitError(&errors.TencentCloudSDKError{Code: "RequestLimitExceededX"}))
+	assert.False(t, IsRateLimitError(io.EOF))
+	assert.False(t, IsRateLimitError(nil))
+}
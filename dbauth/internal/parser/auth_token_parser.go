@@ -5,20 +5,44 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"strings"
 
-	"github.com/golang/protobuf/proto"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
 )
 
+// hashLength is the length of the hex encoded SHA256 integrity hash which prefixes a token.
+const hashLength = 64
+
+// headerLength is the length of the envelope header which precedes the payload in a decrypted token.
+const headerLength = 4
+
+var (
+	// ErrInvalidParam is returned when a parameter required to parse a token is empty.
+	ErrInvalidParam = errors.New("param empty")
+	// ErrMalformedToken is returned when a token is truncated or not in the expected format.
+	ErrMalformedToken = errors.New("malformed token")
+	// ErrDecryptionFailed is returned when a token cannot be decrypted with the key of the requested identity.
+	ErrDecryptionFailed = errors.New("token decryption failed")
+	// ErrIntegrityCheckFailed is returned when the decrypted token does not match its integrity hash.
+	ErrIntegrityCheckFailed = errors.New("token integrity check failed")
+	// ErrIdentityMismatch is returned when the identity embedded in a token differs from the requested one.
+	ErrIdentityMismatch = errors.New("token identity mismatch")
+)
+
 // ParseAuthToken parses the authentication token and returns the authentication token information.
+// The returned errors wrap one of the Err variables of this package, a token with an unknown envelope version or
+// token type is rejected with an UnsupportedTokenFormatError.
 func ParseAuthToken(instanceId, region, userName, token string) (*pb.AuthTokenInfo, error) {
 	if instanceId == "" || region == "" || userName == "" || token == "" {
-		return nil, errors.New("param empty")
+		return nil, ErrInvalidParam
+	}
+	if len(token) <= hashLength {
+		return nil, fmt.Errorf("%w: token length %d is too short", ErrMalformedToken, len(token))
 	}
 
 	// Generate encryption key
@@ -28,7 +52,7 @@ func ParseAuthToken(instanceId, region, userName, token string) (*pb.AuthTokenIn
 	iv := seedKey[33:49]
 
 	// Decrypt AuthToken
-	decToken, err := decrypt(token[64:], key, iv)
+	decToken, err := decrypt(token[hashLength:], key, iv)
 	if err != nil {
 		return nil, err
 	}
@@ -36,23 +60,39 @@ func ParseAuthToken(instanceId, region, userName, token string) (*pb.AuthTokenIn
 	// Compare if the token has been truncated
 	tokenHash := sha256Hash(decToken)
 
-	if token[:64] != tokenHash {
-		return nil, errors.New("token not compare")
+	if subtle.ConstantTimeCompare([]byte(token[:hashLength]), []byte(tokenHash)) != 1 {
+		return nil, ErrIntegrityCheckFailed
 	}
 
 	// Parse token
-	return getAuthTokenInfo(decToken)
+	tokenInfo, err := getAuthTokenInfo(decToken)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIdentity(tokenInfo, instanceId, region, userName); err != nil {
+		return nil, err
+	}
+	return tokenInfo, nil
+}
+
+// checkIdentity checks that the identity embedded in the token matches the requested identity. A token without an
+// identity field is rejected, as it would otherwise match any identity.
+func checkIdentity(tokenInfo *pb.AuthTokenInfo, instanceId, region, userName string) error {
+	if tokenInfo.InstanceId != instanceId {
+		return fmt.Errorf("%w: instanceId %q, expected %q", ErrIdentityMismatch, tokenInfo.InstanceId, instanceId)
+	}
+	if tokenInfo.Region != region {
+		return fmt.Errorf("%w: region %q, expected %q", ErrIdentityMismatch, tokenInfo.Region, region)
+	}
+	if tokenInfo.Username != userName {
+		return fmt.Errorf("%w: username %q, expected %q", ErrIdentityMismatch, tokenInfo.Username, userName)
+	}
+	return nil
 }
 
 // getAuthTokenInfo parses the AuthTokenInfo from the decrypted token
 func getAuthTokenInfo(decToken []byte) (*pb.AuthTokenInfo, error) {
-	subToken := decToken[4:]
-
-	var tokenInfo pb.AuthTokenInfo
-	if err := proto.Unmarshal(subToken, &tokenInfo); err != nil {
-		return nil, fmt.Errorf("failed to parse AuthTokenInfo: %w", err)
-	}
-	return &tokenInfo, nil
+	return decodeEnvelope(decToken)
 }
 
 // sha256Hash calculates the SHA256 hash of the input
@@ -72,18 +112,23 @@ func decrypt(encryptedData, key, iv string) ([]byte, error) {
 
 	cipherText, err := base64Decode(encryptedData)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrMalformedToken, err)
 	}
 
-	if len(cipherText) < aes.BlockSize {
-		return nil, errors.New("ciphertext too short")
+	if len(cipherText) < aes.BlockSize || len(cipherText)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: ciphertext length %d is not a positive multiple of the block size",
+			ErrMalformedToken, len(cipherText))
 	}
 
 	mode := cipher.NewCBCDecrypter(block, ivBytes)
 	decryptedPaddedPlaintext := make([]byte, len(cipherText))
 	mode.CryptBlocks(decryptedPaddedPlaintext, cipherText)
 
-	return unpad(decryptedPaddedPlaintext, aes.BlockSize)
+	plainText, err := unpad(decryptedPaddedPlaintext, aes.BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	return plainText, nil
 }
 
 // base64Decode decodes a base64 string
@@ -0,0 +1,34 @@
+//go:build go1.18
+// +build go1.18
+
+package parser
+
+import (
+	"testing"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+func FuzzParseAuthToken(f *testing.F) {
+	token := newTestToken(f, &pb.AuthTokenInfo{
+		InstanceId: testInstanceId, Region: testRegion, Username: testUserName, Password: "secret"})
+	f.Add(token)
+	f.Add(token[:hashLength])
+	f.Add(token[:len(token)-3])
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, token string) {
+		// Any input must be rejected or parsed without panicking.
+		_, _ = ParseAuthToken(testInstanceId, testRegion, testUserName, token)
+	})
+}
+
+func FuzzGetAuthTokenInfo(f *testing.F) {
+	f.Add([]byte{0, 0, 0, 0})
+	f.Add([]byte{0, 0, 0, 2, 0x42, 0x01})
+	f.Add([]byte{0xff})
+
+	f.Fuzz(func(t *testing.T, decToken []byte) {
+		_, _ = getAuthTokenInfo(decToken)
+	})
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+const (
+	testInstanceId = "cdb-123456"
+	testRegion     = "ap-guangzhou"
+	testUserName   = "camtest"
+)
+
+func newTestToken(t testing.TB, tokenInfo *pb.AuthTokenInfo) string {
+	token, err := dbauthtest.NewAuthToken(testInstanceId, testRegion, testUserName, tokenInfo)
+	assert.NoError(t, err)
+	return token
+}
+
+// encrypt encrypts the input using the key and iv, the inverse of decrypt
+func encrypt(data []byte, key, iv string) (string, error) {
+	block, err := aes.NewCipher([]byte(key))
+	if err != nil {
+		return "", err
+	}
+
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	plainText := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipherText := make([]byte, len(plainText))
+	cipher.NewCBCEncrypter(block, []byte(iv)).CryptBlocks(cipherText, plainText)
+
+	return base64.RawURLEncoding.EncodeToString(cipherText), nil
+}
+
+func TestParseAuthToken_ValidToken(t *testing.T) {
+	token := newTestToken(t, &pb.AuthTokenInfo{
+		InstanceId: testInstanceId, Region: testRegion, Username: testUserName, Password: "secret"})
+
+	tokenInfo, err := ParseAuthToken(testInstanceId, testRegion, testUserName, token)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", tokenInfo.Password)
+}
+
+func TestParseAuthToken_VersionedToken(t *testing.T) {
+	payload, err := proto.Marshal(&pb.AuthTokenInfo{
+		InstanceId: testInstanceId, Region: testRegion, Username: testUserName, Password: "secret"})
+	assert.NoError(t, err)
+	RegisterPayloadDecoder(2, func(payload []byte) (*pb.AuthTokenInfo, error) {
+		tokenInfo, err := decodeProtobufPayload(payload)
+		if err != nil {
+			return nil, err
+		}
+		tokenInfo.ExtraInfo = "version 2"
+		return tokenInfo, nil
+	})
+	RegisterTokenTypeDecoder(2, TokenTypeDefault, decodePasswordToken)
+	defer func() {
+		decodersMu.Lock()
+		delete(payloadDecoders, 2)
+		delete(tokenTypeDecoders, tokenTypeKey{version: 2, tokenType: TokenTypeDefault})
+		decodersMu.Unlock()
+	}()
+
+	for version, extraInfo := range map[uint8]string{EnvelopeVersion0: "", 2: "version 2"} {
+		token, err := dbauthtest.NewVersionedAuthToken(testInstanceId, testRegion, testUserName, version, payload)
+		assert.NoError(t, err)
+
+		tokenInfo, err := ParseAuthToken(testInstanceId, testRegion, testUserName, token)
+		assert.NoError(t, err, "version %d", version)
+		assert.Equal(t, "secret", tokenInfo.Password)
+		assert.Equal(t, extraInfo, tokenInfo.ExtraInfo)
+	}
+
+	token, err := dbauthtest.NewVersionedAuthToken(testInstanceId, testRegion, testUserName, 3, payload)
+	assert.NoError(t, err)
+	_, err = ParseAuthToken(testInstanceId, testRegion, testUserName, token)
+	assert.True(t, errors.Is(err, ErrUnsupportedTokenFormat))
+}
+
+func TestParseAuthToken_EmptyParam(t *testing.T) {
+	_, err := ParseAuthToken("", testRegion, testUserName, "token")
+	assert.True(t, errors.Is(err, ErrInvalidParam))
+}
+
+func TestParseAuthToken_ShortToken(t *testing.T) {
+	_, err := ParseAuthToken(testInstanceId, testRegion, testUserName, "abc")
+	assert.True(t, errors.Is(err, ErrMalformedToken))
+}
+
+func TestParseAuthToken_HashOnly(t *testing.T) {
+	_, err := ParseAuthToken(testInstanceId, testRegion, testUserName, strings.Repeat("a", hashLength))
+	assert.True(t, errors.Is(err, ErrMalformedToken))
+}
+
+func TestParseAuthToken_InvalidBase64(t *testing.T) {
+	_, err := ParseAuthToken(testInstanceId, testRegion, testUserName, strings.Repeat("a", hashLength)+"!!!!")
+	assert.True(t, errors.Is(err, ErrMalformedToken))
+}
+
+func TestParseAuthToken_PartialBlock(t *testing.T) {
+	token := strings.Repeat("a", hashLength) + strings.Repeat("A", 30)
+	_, err := ParseAuthToken(testInstanceId, testRegion, testUserName, token)
+	assert.True(t, errors.Is(err, ErrMalformedToken))
+}
+
+func TestParseAuthToken_TamperedHash(t *testing.T) {
+	token := newTestToken(t, &pb.AuthTokenInfo{Password: "secret"})
+	tampered := strings.Repeat("0", hashLength) + token[hashLength:]
+
+	_, err := ParseAuthToken(testInstanceId, testRegion, testUserName, tampered)
+	assert.True(t, errors.Is(err, ErrIntegrityCheckFailed))
+}
+
+func TestParseAuthToken_ShortPlaintext(t *testing.T) {
+	seedKey := sha256Hash([]byte(testInstanceId + constants.DELIMITER + testRegion + constants.DELIMITER + testUserName))
+	encToken, err := encrypt([]byte{1, 2}, seedKey[:32], seedKey[33:49])
+	assert.NoError(t, err)
+
+	_, err = ParseAuthToken(testInstanceId, testRegion, testUserName, sha256Hash([]byte{1, 2})+encToken)
+	assert.True(t, errors.Is(err, ErrMalformedToken))
+}
+
+func TestParseAuthToken_IdentityMismatch(t *testing.T) {
+	token := newTestToken(t, &pb.AuthTokenInfo{
+		InstanceId: "cdb-654321", Region: testRegion, Username: testUserName, Password: "secret"})
+
+	_, err := ParseAuthToken(testInstanceId, testRegion, testUserName, token)
+	assert.True(t, errors.Is(err, ErrIdentityMismatch))
+}
+
+func TestParseAuthToken_MissingIdentity(t *testing.T) {
+	for _, tokenInfo := range []*pb.AuthTokenInfo{
+		{Region: testRegion, Username: testUserName, Password: "secret"},
+		{InstanceId: testInstanceId, Username: testUserName, Password: "secret"},
+		{InstanceId: testInstanceId, Region: testRegion, Password: "secret"},
+	} {
+		_, err := ParseAuthToken(testInstanceId, testRegion, testUserName, newTestToken(t, tokenInfo))
+		assert.True(t, errors.Is(err, ErrIdentityMismatch), "%v", tokenInfo)
+	}
+}
+
+func TestParseAuthToken_WrongKey(t *testing.T) {
+	token := newTestToken(t, &pb.AuthTokenInfo{Password: "secret"})
+
+	_, err := ParseAuthToken("cdb-654321", testRegion, testUserName, token)
+	assert.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+// A decrypted token is an envelope: a 4 bytes big-endian header followed by the payload. The high byte of the
+// header is the envelope version and the low 3 bytes are the payload length. The version 0 payload is a
+// protobuf AuthTokenInfo. A zero header, or 4 bytes whose length does not match the payload and so are no header,
+// precede the payload of the tokens issued before the envelope versions: the payload is decoded as version 0, as
+// it always was.
+
+const (
+	// EnvelopeVersion0 is the version of the envelopes carrying a protobuf AuthTokenInfo.
+	EnvelopeVersion0 uint8 = 0
+	// TokenTypeDefault is the token type of the tokens which do not set one.
+	TokenTypeDefault uint32 = 0
+	// TokenTypeDataFlow is the token type of the data flow authentication tokens.
+	TokenTypeDataFlow uint32 = 1
+	// maxPayloadLength is the largest payload length the header can describe.
+	maxPayloadLength = 1<<24 - 1
+)
+
+// ErrUnsupportedTokenFormat is matched by errors.Is for every UnsupportedTokenFormatError.
+var ErrUnsupportedTokenFormat = errors.New("unsupported token format")
+
+// UnsupportedTokenFormatError is returned when a token envelope version or token type has no registered decoder.
+type UnsupportedTokenFormatError struct {
+	Version uint8
+	// TokenType is the token type without a decoder, only set when the version is supported.
+	TokenType uint32
+	// VersionSupported is whether a payload decoder is registered for the version.
+	VersionSupported bool
+}
+
+// Error returns the error message.
+func (e *UnsupportedTokenFormatError) Error() string {
+	if !e.VersionSupported {
+		return fmt.Sprintf("%v: envelope version %d", ErrUnsupportedTokenFormat, e.Version)
+	}
+	return fmt.Sprintf("%v: envelope version %d, token type %d", ErrUnsupportedTokenFormat, e.Version, e.TokenType)
+}
+
+// Is reports whether the target is ErrUnsupportedTokenFormat.
+func (e *UnsupportedTokenFormatError) Is(target error) bool {
+	return target == ErrUnsupportedTokenFormat
+}
+
+// PayloadDecoder decodes the payload of an envelope version into the authentication token information.
+type PayloadDecoder func(payload []byte) (*pb.AuthTokenInfo, error)
+
+// TokenTypeDecoder validates and completes the authentication token information of a token type.
+type TokenTypeDecoder func(tokenInfo *pb.AuthTokenInfo) error
+
+type tokenTypeKey struct {
+	version   uint8
+	tokenType uint32
+}
+
+var (
+	decodersMu        sync.RWMutex
+	payloadDecoders   = map[uint8]PayloadDecoder{}
+	tokenTypeDecoders = map[tokenTypeKey]TokenTypeDecoder{}
+)
+
+func init() {
+	RegisterPayloadDecoder(EnvelopeVersion0, decodeProtobufPayload)
+	RegisterTokenTypeDecoder(EnvelopeVersion0, TokenTypeDefault, decodePasswordToken)
+	RegisterTokenTypeDecoder(EnvelopeVersion0, TokenTypeDataFlow, decodePasswordToken)
+}
+
+// RegisterPayloadDecoder registers the payload decoder of an envelope version, replacing any previous one.
+func RegisterPayloadDecoder(version uint8, decoder PayloadDecoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	payloadDecoders[version] = decoder
+}
+
+// RegisterTokenTypeDecoder registers the decoder of a token type carried by an envelope version,
+// replacing any previous one.
+func RegisterTokenTypeDecoder(version uint8, tokenType uint32, decoder TokenTypeDecoder) {
+	decodersMu.Lock()
+	defer decodersMu.Unlock()
+	tokenTypeDecoders[tokenTypeKey{version: version, tokenType: tokenType}] = decoder
+}
+
+// envelopeHeader represents the header of a decrypted token.
+type envelopeHeader struct {
+	version uint8
+	length  uint32
+}
+
+// parseEnvelope splits the decrypted token into its header and payload.
+func parseEnvelope(decToken []byte) (envelopeHeader, []byte, error) {
+	if len(decToken) < headerLength {
+		return envelopeHeader{}, nil, fmt.Errorf("%w: decrypted token length %d is too short",
+			ErrMalformedToken, len(decToken))
+	}
+	header := binary.BigEndian.Uint32(decToken[:headerLength])
+	return envelopeHeader{version: uint8(header >> 24), length: header & maxPayloadLength},
+		decToken[headerLength:], nil
+}
+
+// decodeEnvelope decodes the authentication token information from a decrypted token with the registered decoders.
+func decodeEnvelope(decToken []byte) (*pb.AuthTokenInfo, error) {
+	header, payload, err := parseEnvelope(decToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if header == (envelopeHeader{}) || int(header.length) != len(payload) {
+		// The token has a zero header or no header, decode its payload as version 0.
+		return decodePayload(EnvelopeVersion0, payload)
+	}
+	return decodePayload(header.version, payload)
+}
+
+// decodePayload decodes the payload with the decoders registered for the envelope version.
+func decodePayload(version uint8, payload []byte) (*pb.AuthTokenInfo, error) {
+	decodersMu.RLock()
+	payloadDecoder, ok := payloadDecoders[version]
+	decodersMu.RUnlock()
+	if !ok {
+		return nil, &UnsupportedTokenFormatError{Version: version}
+	}
+	tokenInfo, err := payloadDecoder(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	decodersMu.RLock()
+	tokenTypeDecoder, ok := tokenTypeDecoders[tokenTypeKey{version: version, tokenType: tokenInfo.TokenType}]
+	decodersMu.RUnlock()
+	if !ok {
+		return nil, &UnsupportedTokenFormatError{
+			Version: version, TokenType: tokenInfo.TokenType, VersionSupported: true}
+	}
+	if err := tokenTypeDecoder(tokenInfo); err != nil {
+		return nil, err
+	}
+	return tokenInfo, nil
+}
+
+// decodeProtobufPayload decodes a version 0 payload
+func decodeProtobufPayload(payload []byte) (*pb.AuthTokenInfo, error) {
+	var tokenInfo pb.AuthTokenInfo
+	if err := proto.Unmarshal(payload, &tokenInfo); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse AuthTokenInfo: %v", ErrMalformedToken, err)
+	}
+	return &tokenInfo, nil
+}
+
+// decodePasswordToken accepts the token information of the token types carrying the password as it is decoded
+func decodePasswordToken(*pb.AuthTokenInfo) error {
+	return nil
+}
@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+func newTestEnvelope(t *testing.T, version uint8, tokenInfo *pb.AuthTokenInfo) []byte {
+	payload, err := proto.Marshal(tokenInfo)
+	assert.NoError(t, err)
+	decToken, err := newEnvelope(version, payload)
+	assert.NoError(t, err)
+	return decToken
+}
+
+// newEnvelope builds a decrypted token from the envelope version and payload.
+func newEnvelope(version uint8, payload []byte) ([]byte, error) {
+	if len(payload) > maxPayloadLength {
+		return nil, fmt.Errorf("payload length %d exceeds %d", len(payload), maxPayloadLength)
+	}
+	decToken := make([]byte, headerLength, headerLength+len(payload))
+	binary.BigEndian.PutUint32(decToken, uint32(version)<<24|uint32(len(payload)))
+	return append(decToken, payload...), nil
+}
+
+func TestParseEnvelope_Header(t *testing.T) {
+	header, payload, err := parseEnvelope([]byte{2, 0, 1, 0, 0xaa})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(2), header.version)
+	assert.Equal(t, uint32(256), header.length)
+	assert.Equal(t, []byte{0xaa}, payload)
+}
+
+func TestDecodeEnvelope_Version0(t *testing.T) {
+	tokenInfo, err := decodeEnvelope(newTestEnvelope(t, EnvelopeVersion0,
+		&pb.AuthTokenInfo{Password: "secret", TokenType: TokenTypeDataFlow}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", tokenInfo.Password)
+	assert.Equal(t, TokenTypeDataFlow, tokenInfo.TokenType)
+}
+
+func TestDecodeEnvelope_ZeroHeader(t *testing.T) {
+	payload, err := proto.Marshal(&pb.AuthTokenInfo{Password: "secret"})
+	assert.NoError(t, err)
+
+	tokenInfo, err := decodeEnvelope(append([]byte{0, 0, 0, 0}, payload...))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", tokenInfo.Password)
+}
+
+func TestDecodeEnvelope_UnsupportedVersion(t *testing.T) {
+	_, err := decodeEnvelope([]byte{9, 0, 0, 1, 0xff})
+
+	assert.True(t, errors.Is(err, ErrUnsupportedTokenFormat))
+	var formatErr *UnsupportedTokenFormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.Equal(t, uint8(9), formatErr.Version)
+	assert.False(t, formatErr.VersionSupported)
+}
+
+func TestDecodeEnvelope_UnknownVersionNotDecodedAsVersion0(t *testing.T) {
+	// The payload of an unknown version is rejected even if it happens to be a valid version 0 payload.
+	_, err := decodeEnvelope(newTestEnvelope(t, 9, &pb.AuthTokenInfo{Password: "secret"}))
+
+	var formatErr *UnsupportedTokenFormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.Equal(t, uint8(9), formatErr.Version)
+	assert.False(t, formatErr.VersionSupported)
+}
+
+func TestDecodeEnvelope_OpaqueHeader(t *testing.T) {
+	// 4 bytes which do not describe the payload are no header, and are skipped as the parser always did.
+	payload, err := proto.Marshal(&pb.AuthTokenInfo{Password: "secret", TokenType: 1})
+	assert.NoError(t, err)
+
+	tokenInfo, err := decodeEnvelope(append([]byte{0x0a, 0x0b, 0x0c, 0x0d}, payload...))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", tokenInfo.Password)
+}
+
+func TestDecodeEnvelope_UnsupportedTokenType(t *testing.T) {
+	RegisterPayloadDecoder(8, decodeProtobufPayload)
+	RegisterTokenTypeDecoder(8, 1, decodePasswordToken)
+	defer func() {
+		decodersMu.Lock()
+		delete(payloadDecoders, 8)
+		delete(tokenTypeDecoders, tokenTypeKey{version: 8, tokenType: 1})
+		decodersMu.Unlock()
+	}()
+
+	_, err := decodeEnvelope(newTestEnvelope(t, 8, &pb.AuthTokenInfo{Password: "secret", TokenType: 1}))
+	assert.NoError(t, err)
+
+	_, err = decodeEnvelope(newTestEnvelope(t, 8, &pb.AuthTokenInfo{Password: "secret", TokenType: 2}))
+	var formatErr *UnsupportedTokenFormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.True(t, formatErr.VersionSupported)
+	assert.Equal(t, uint32(2), formatErr.TokenType)
+}
+
+func TestDecodeEnvelope_UnknownVersion0TokenType(t *testing.T) {
+	_, err := decodeEnvelope(newTestEnvelope(t, EnvelopeVersion0, &pb.AuthTokenInfo{Password: "secret", TokenType: 7}))
+
+	var formatErr *UnsupportedTokenFormatError
+	assert.True(t, errors.As(err, &formatErr))
+	assert.True(t, formatErr.VersionSupported)
+	assert.Equal(t, uint32(7), formatErr.TokenType)
+}
+
+func TestDecodeEnvelope_LengthMismatch(t *testing.T) {
+	decToken := newTestEnvelope(t, EnvelopeVersion0, &pb.AuthTokenInfo{Password: "secret"})
+
+	_, err := decodeEnvelope(decToken[:len(decToken)-1])
+	assert.True(t, errors.Is(err, ErrMalformedToken))
+
+	// A payload which does not match the header length is still decoded when it is valid.
+	decToken[3]++
+	tokenInfo, err := decodeEnvelope(decToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", tokenInfo.Password)
+}
+
+func TestDecodeEnvelope_NoPassword(t *testing.T) {
+	tokenInfo, err := decodeEnvelope(newTestEnvelope(t, EnvelopeVersion0, &pb.AuthTokenInfo{AppId: 1}))
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), tokenInfo.AppId)
+	assert.Empty(t, tokenInfo.Password)
+}
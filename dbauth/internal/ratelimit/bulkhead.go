@@ -0,0 +1,26 @@
+package ratelimit
+
+// Bulkhead limits the number of concurrent requests.
+type Bulkhead struct {
+	slots chan struct{}
+}
+
+// NewBulkhead creates a new Bulkhead which allows up to maxConcurrent concurrent requests.
+func NewBulkhead(maxConcurrent int) *Bulkhead {
+	return &Bulkhead{slots: make(chan struct{}, maxConcurrent)}
+}
+
+// Acquire blocks until a slot is free and takes it.
+func (b *Bulkhead) Acquire() {
+	b.slots <- struct{}{}
+}
+
+// Release frees a slot taken with Acquire.
+func (b *Bulkhead) Release() {
+	<-b.slots
+}
+
+// InUse returns the number of slots taken.
+func (b *Bulkhead) InUse() int {
+	return len(b.slots)
+}
@@ -0,0 +1,29 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkhead_BlocksWhenFull(t *testing.T) {
+	bulkhead := NewBulkhead(1)
+	bulkhead.Acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		bulkhead.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the second request acquired a slot of a full bulkhead")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	bulkhead.Release()
+	<-acquired
+	assert.Equal(t, 1, bulkhead.InUse())
+}
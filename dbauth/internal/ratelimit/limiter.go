@@ -0,0 +1,117 @@
+// Package ratelimit provides the rate limiter and the concurrency limit of the CAM requests of a client.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+)
+
+const (
+	// minRateDivisor bounds the rate a limiter can be slowed down to, as a fraction of its configured rate.
+	minRateDivisor = 32
+	// recoverySteps is the number of successful requests a limiter takes to get back from its minimum rate to
+	// its configured rate.
+	recoverySteps = 32
+)
+
+// Limiter is a token bucket rate limiter which adapts its rate to the throttling of the server: the rate is halved
+// each time a request is throttled, and grows back linearly with the successful requests up to the configured rate.
+type Limiter struct {
+	clock   clock.Clock
+	maxRate float64
+	burst   float64
+
+	mu     sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter creates a new Limiter which allows rate requests per second with bursts of up to burst requests.
+// The bucket starts full.
+func NewLimiter(rate float64, burst int, limiterClock clock.Clock) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		clock:   limiterClock,
+		maxRate: rate,
+		burst:   float64(burst),
+		rate:    rate,
+		tokens:  float64(burst),
+		last:    limiterClock.Now(),
+	}
+}
+
+// Reserve takes a token from the bucket and returns how long the caller must wait before sending its request.
+func (l *Limiter) Reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance()
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// Wait takes a token from the bucket and blocks until the request can be sent. It returns how long it waited.
+func (l *Limiter) Wait() time.Duration {
+	delay := l.Reserve()
+	if delay > 0 {
+		done := make(chan struct{})
+		l.clock.AfterFunc(delay, func() { close(done) })
+		<-done
+	}
+	return delay
+}
+
+// Throttled halves the rate after a request was throttled by the server.
+func (l *Limiter) Throttled() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.advance()
+	l.rate /= 2
+	if minRate := l.maxRate / minRateDivisor; l.rate < minRate {
+		l.rate = minRate
+	}
+}
+
+// Succeeded grows the rate back towards the configured rate after a request succeeded.
+func (l *Limiter) Succeeded() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate >= l.maxRate {
+		return
+	}
+	l.advance()
+	l.rate += l.maxRate / recoverySteps
+	if l.rate > l.maxRate {
+		l.rate = l.maxRate
+	}
+}
+
+// Rate returns the current rate, in requests per second.
+func (l *Limiter) Rate() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// advance adds the tokens accumulated since the last update at the current rate. It must be called with the lock
+// held, before the rate is changed.
+func (l *Limiter) advance() {
+	now := l.clock.Now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+}
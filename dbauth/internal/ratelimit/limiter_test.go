@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+)
+
+func TestLimiter_Burst(t *testing.T) {
+	limiter := NewLimiter(2, 3, dbauthtest.NewFakeClock(time.Now()))
+
+	assert.Equal(t, time.Duration(0), limiter.Reserve())
+	assert.Equal(t, time.Duration(0), limiter.Reserve())
+	assert.Equal(t, time.Duration(0), limiter.Reserve())
+	assert.Equal(t, 500*time.Millisecond, limiter.Reserve())
+	assert.Equal(t, time.Second, limiter.Reserve())
+}
+
+func TestLimiter_Refill(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	limiter := NewLimiter(2, 1, fakeClock)
+
+	assert.Equal(t, time.Duration(0), limiter.Reserve())
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.Equal(t, time.Duration(0), limiter.Reserve())
+
+	// The bucket does not fill beyond the burst.
+	fakeClock.Advance(time.Hour)
+	assert.Equal(t, time.Duration(0), limiter.Reserve())
+	assert.Equal(t, 500*time.Millisecond, limiter.Reserve())
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	limiter := NewLimiter(1, 1, fakeClock)
+	limiter.Reserve()
+
+	waited := make(chan time.Duration)
+	go func() { waited <- limiter.Wait() }()
+	for fakeClock.PendingTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	fakeClock.Advance(time.Second)
+
+	assert.Equal(t, time.Second, <-waited)
+}
+
+func TestLimiter_AdaptsToThrottling(t *testing.T) {
+	limiter := NewLimiter(32, 1, dbauthtest.NewFakeClock(time.Now()))
+
+	limiter.Throttled()
+	assert.Equal(t, 16.0, limiter.Rate())
+	limiter.Succeeded()
+	assert.Equal(t, 17.0, limiter.Rate())
+
+	for i := 0; i < 10; i++ {
+		limiter.Throttled()
+	}
+	assert.Equal(t, 1.0, limiter.Rate())
+
+	for i := 0; i < 40; i++ {
+		limiter.Succeeded()
+	}
+	assert.Equal(t, 32.0, limiter.Rate())
+}
@@ -0,0 +1,144 @@
+// Package sealer provides the authenticated encryption of the files written by the SDK.
+package sealer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// A sealed file is the magic, a version byte, and the sealed payload of the version. The version 1 payload is a
+// 12 bytes nonce followed by the AES-256-GCM ciphertext of the plaintext, the magic and the version being the
+// additional authenticated data.
+
+const (
+	// Version1 is the version of the files sealed with AES-256-GCM.
+	Version1 uint8 = 1
+	// KeySize is the size in bytes of a key.
+	KeySize = 32
+)
+
+// magic starts every sealed file.
+var magic = []byte("DBAUTHSEALED")
+
+// headerLength is the length of the magic and the version.
+var headerLength = len(magic) + 1
+
+// ErrInvalidKey is returned when a key is not KeySize bytes long.
+var ErrInvalidKey = fmt.Errorf("the key must be %d bytes long", KeySize)
+
+// IsSealed reports whether the data starts with the magic of a sealed file.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, magic)
+}
+
+// Seal encrypts the plaintext with the key into a sealed file of the latest version.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, magic...), Version1)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate the nonce: %w", err)
+	}
+	sealed := append(header, nonce...)
+	return aead.Seal(sealed, nonce, plaintext, header), nil
+}
+
+// Open decrypts and authenticates a sealed file with the key.
+func Open(key, sealed []byte) ([]byte, error) {
+	if !IsSealed(sealed) || len(sealed) < headerLength {
+		return nil, errors.New("the data is not sealed")
+	}
+	header, payload := sealed[:headerLength], sealed[headerLength:]
+	if version := header[len(magic)]; version != Version1 {
+		return nil, fmt.Errorf("unsupported sealed file version %d", version)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("the sealed data is truncated")
+	}
+	nonce, ciphertext := payload[:aead.NonceSize()], payload[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, errors.New("failed to open the sealed data: wrong key or corrupted data")
+	}
+	return plaintext, nil
+}
+
+// WriteFile seals the plaintext with the key and writes it to the path with the mode 0600. The file is replaced
+// atomically, so a concurrent reader never sees a partial file.
+func WriteFile(path string, key, plaintext []byte) error {
+	sealed, err := Seal(key, plaintext)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create the temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(sealed); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the temporary file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close the temporary file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace the file %s: %w", path, err)
+	}
+	return nil
+}
+
+// GenerateKey returns a new random key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate the key: %w", err)
+	}
+	return key, nil
+}
+
+// ParseKey parses a key encoded in standard base64, surrounding white space being ignored, or given as its
+// KeySize raw bytes.
+func ParseKey(encoded []byte) ([]byte, error) {
+	if len(encoded) == KeySize {
+		return encoded, nil
+	}
+	trimmed := bytes.TrimSpace(encoded)
+	key := make([]byte, base64.StdEncoding.DecodedLen(len(trimmed)))
+	n, err := base64.StdEncoding.Decode(key, trimmed)
+	if err != nil || n != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return key[:n], nil
+}
+
+// newAEAD creates the AES-256-GCM cipher of the key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
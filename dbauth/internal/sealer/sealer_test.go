@@ -0,0 +1,66 @@
+package sealer
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealOpen(t *testing.T) {
+	key, err := GenerateKey()
+	assert.NoError(t, err)
+
+	sealed, err := Seal(key, []byte("password"))
+	assert.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "password")
+
+	plaintext, err := Open(key, sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", string(plaintext))
+}
+
+func TestOpen_Rejects(t *testing.T) {
+	key, _ := GenerateKey()
+	otherKey, _ := GenerateKey()
+	sealed, err := Seal(key, []byte("password"))
+	assert.NoError(t, err)
+
+	_, err = Open(otherKey, sealed)
+	assert.Error(t, err)
+
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+	_, err = Open(key, tampered)
+	assert.Error(t, err)
+
+	unsupported := append([]byte{}, sealed...)
+	unsupported[len(magic)] = 9
+	_, err = Open(key, unsupported)
+	assert.EqualError(t, err, "unsupported sealed file version 9")
+
+	_, err = Open(key, sealed[:headerLength+4])
+	assert.Error(t, err)
+	_, err = Open(key, []byte("password"))
+	assert.Error(t, err)
+	_, err = Seal(key[:16], []byte("password"))
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestParseKey(t *testing.T) {
+	key, _ := GenerateKey()
+
+	parsed, err := ParseKey([]byte(base64.StdEncoding.EncodeToString(key) + "\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	parsed, err = ParseKey(key)
+	assert.NoError(t, err)
+	assert.Equal(t, key, parsed)
+
+	_, err = ParseKey([]byte("c2hvcnQ="))
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = ParseKey([]byte("not base64!"))
+	assert.Equal(t, ErrInvalidKey, err)
+}
@@ -0,0 +1,112 @@
+package signer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
+	cam "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+)
+
+// clientPool shares the CAM clients of an engine between its signers, so that the connections to CAM are kept
+// alive across the refreshes. A client is kept per secret id, region and profile, and replaced when it is requested
+// with another secret key or token of its secret id, so that rotated keys and tokens do not grow the pool.
+type clientPool struct {
+	// transport sends the requests of every client, if not nil.
+	transport http.RoundTripper
+	// keepAliveTransport sends the requests of the clients when transport is nil and the profile sets no proxy.
+	keepAliveTransport http.RoundTripper
+
+	mu      sync.Mutex
+	clients map[string]*pooledClient
+}
+
+// pooledClient is a CAM client and the fingerprint of the secrets of the credential it was created with.
+type pooledClient struct {
+	fingerprint string
+	client      *cam.Client
+}
+
+// newClientPool creates a new clientPool whose clients send their requests with the provided transport, or with
+// a transport shared by the clients if nil.
+func newClientPool(transport http.RoundTripper) *clientPool {
+	pool := &clientPool{transport: transport, clients: map[string]*pooledClient{}}
+	if transport == nil {
+		if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+			pool.keepAliveTransport = defaultTransport.Clone()
+		}
+	}
+	return pool
+}
+
+// get returns the CAM client of the credential, the region and the profile, creating it if there is none or if
+// the pooled one was created with another secret key or token.
+func (p *clientPool) get(credential *common.Credential, region string,
+	clientProfile *profile.ClientProfile) (*cam.Client, error) {
+	key := credential.SecretId + constants.DELIMITER + region + constants.DELIMITER + profileKey(clientProfile)
+	fingerprint := credentialFingerprint(credential)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if pooled, ok := p.clients[key]; ok {
+		if pooled.fingerprint == fingerprint {
+			return pooled.client, nil
+		}
+		logging.Infof("The credential of the CAM client of region %s has been rotated, replacing the client", region)
+	}
+
+	// The client keeps its own copy of the credential, so that a later change of the credential of the request
+	// is picked up by the fingerprint instead of being shared with the pooled client.
+	client, err := cam.NewClient(common.NewTokenCredential(credential.SecretId, credential.SecretKey,
+		credential.Token), region, clientProfile)
+	if err != nil {
+		return nil, err
+	}
+	if transport := p.transportFor(clientProfile); transport != nil {
+		client.WithHttpTransport(transport)
+	}
+	p.clients[key] = &pooledClient{fingerprint: fingerprint, client: client}
+	return client, nil
+}
+
+// transportFor returns the transport of a client with the provided profile, nil to keep the transport of the CAM
+// client, which carries the proxy of the profile.
+func (p *clientPool) transportFor(clientProfile *profile.ClientProfile) http.RoundTripper {
+	if p.transport != nil {
+		return p.transport
+	}
+	if clientProfile.HttpProfile != nil && clientProfile.HttpProfile.Proxy != "" {
+		return nil
+	}
+	return p.keepAliveTransport
+}
+
+// len returns the number of pooled clients.
+func (p *clientPool) len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.clients)
+}
+
+// credentialFingerprint returns a hash of the secret key and the token of the credential, so that the secrets are
+// not kept in the pool.
+func credentialFingerprint(credential *common.Credential) string {
+	hash := sha256.Sum256([]byte(credential.SecretKey + "\x00" + credential.Token))
+	return hex.EncodeToString(hash[:])
+}
+
+// profileKey returns a key which is equal for profiles with the same settings.
+func profileKey(clientProfile *profile.ClientProfile) string {
+	settings := *clientProfile
+	settings.HttpProfile = nil
+	if clientProfile.HttpProfile == nil {
+		return fmt.Sprintf("%+v", settings)
+	}
+	return fmt.Sprintf("%+v|%+v", *clientProfile.HttpProfile, settings)
+}
@@ -0,0 +1,85 @@
+package signer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+)
+
+func TestClientPool_ReusesClientOfEqualProfiles(t *testing.T) {
+	pool := newClientPool(nil)
+
+	first, err := pool.get(common.NewCredential("secretId", "secretKey"), "ap-guangzhou", profile.NewClientProfile())
+	assert.NoError(t, err)
+	second, err := pool.get(common.NewCredential("secretId", "secretKey"), "ap-guangzhou", profile.NewClientProfile())
+	assert.NoError(t, err)
+
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, pool.len())
+}
+
+func TestClientPool_SeparatesRegionsAndProfiles(t *testing.T) {
+	pool := newClientPool(nil)
+	credential := common.NewCredential("secretId", "secretKey")
+	otherProfile := profile.NewClientProfile()
+	otherProfile.HttpProfile.Endpoint = "cam.internal.tencentcloudapi.com"
+
+	first, err := pool.get(credential, "ap-guangzhou", profile.NewClientProfile())
+	assert.NoError(t, err)
+	second, err := pool.get(credential, "ap-shanghai", profile.NewClientProfile())
+	assert.NoError(t, err)
+	third, err := pool.get(credential, "ap-guangzhou", otherProfile)
+	assert.NoError(t, err)
+
+	assert.NotSame(t, first, second)
+	assert.NotSame(t, first, third)
+	assert.Equal(t, 3, pool.len())
+}
+
+func TestClientPool_ReplacesClientOfRotatedCredential(t *testing.T) {
+	pool := newClientPool(nil)
+
+	first, err := pool.get(common.NewCredential("secretId", "secretKey"), "ap-guangzhou", profile.NewClientProfile())
+	assert.NoError(t, err)
+	second, err := pool.get(common.NewCredential("secretId", "rotatedKey"), "ap-guangzhou", profile.NewClientProfile())
+	assert.NoError(t, err)
+
+	assert.NotSame(t, first, second)
+	assert.Equal(t, 1, pool.len())
+	secretId, secretKey, _ := second.GetCredential().GetCredential()
+	assert.Equal(t, "secretId", secretId)
+	assert.Equal(t, "rotatedKey", secretKey)
+}
+
+func TestClientPool_KeepsClientsOfAlternatingCredentials(t *testing.T) {
+	pool := newClientPool(nil)
+	first := common.NewCredential("secretId1", "secretKey1")
+	second := common.NewCredential("secretId2", "secretKey2")
+
+	firstClient, err := pool.get(first, "ap-guangzhou", profile.NewClientProfile())
+	assert.NoError(t, err)
+	secondClient, err := pool.get(second, "ap-guangzhou", profile.NewClientProfile())
+	assert.NoError(t, err)
+	assert.NotSame(t, firstClient, secondClient)
+
+	for i := 0; i < 3; i++ {
+		client, err := pool.get(first, "ap-guangzhou", profile.NewClientProfile())
+		assert.NoError(t, err)
+		assert.Same(t, firstClient, client)
+		client, err = pool.get(second, "ap-guangzhou", profile.NewClientProfile())
+		assert.NoError(t, err)
+		assert.Same(t, secondClient, client)
+	}
+	assert.Equal(t, 2, pool.len())
+}
+
+func TestClientPool_KeepsTransportOfProfileProxy(t *testing.T) {
+	pool := newClientPool(nil)
+	proxyProfile := profile.NewClientProfile()
+	proxyProfile.HttpProfile.Proxy = "http://proxy.example.com:3128"
+
+	assert.Nil(t, pool.transportFor(proxyProfile))
+	assert.Same(t, pool.keepAliveTransport, pool.transportFor(profile.NewClientProfile()))
+}
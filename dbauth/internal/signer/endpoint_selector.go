@@ -0,0 +1,89 @@
+package signer
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+// endpointSelector chooses the CAM endpoint of each request among the ordered endpoints of an engine. An endpoint
+// which failed with a network error is skipped until its cooldown ends, so that the requests fail over to the next
+// endpoint and fail back to the preferred one afterwards.
+type endpointSelector struct {
+	endpoints []string
+	cooldown  time.Duration
+	clock     clock.Clock
+
+	mu             sync.Mutex
+	unhealthyUntil map[string]time.Time
+}
+
+// newEndpointSelector creates a new endpointSelector with the provided ordered endpoints.
+func newEndpointSelector(endpoints []string, cooldown time.Duration, selectorClock clock.Clock) *endpointSelector {
+	return &endpointSelector{
+		endpoints:      endpoints,
+		cooldown:       cooldown,
+		clock:          selectorClock,
+		unhealthyUntil: map[string]time.Time{},
+	}
+}
+
+// candidates returns the endpoints of the region in the order they should be tried: the endpoints which are not
+// cooling down in their configured order, then the others by the end of their cooldown. It returns a single empty
+// string if there are no endpoints.
+func (s *endpointSelector) candidates(region string) []string {
+	if len(s.endpoints) == 0 {
+		return []string{""}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	healthy := make([]string, 0, len(s.endpoints))
+	var coolingDown []string
+	for _, template := range s.endpoints {
+		endpoint := strings.Replace(template, model.RegionPlaceholder, region, -1)
+		if until, ok := s.unhealthyUntil[endpoint]; ok && now.Before(until) {
+			coolingDown = append(coolingDown, endpoint)
+		} else {
+			healthy = append(healthy, endpoint)
+		}
+	}
+	sort.SliceStable(coolingDown, func(i, j int) bool {
+		return s.unhealthyUntil[coolingDown[i]].Before(s.unhealthyUntil[coolingDown[j]])
+	})
+	return append(healthy, coolingDown...)
+}
+
+// markUnhealthy skips the endpoint until its cooldown ends.
+func (s *endpointSelector) markUnhealthy(endpoint string) {
+	if endpoint == "" || len(s.endpoints) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.unhealthyUntil[endpoint]; !ok {
+		logging.Warnf("The CAM endpoint %s failed, skipping it for %v", endpoint, s.cooldown)
+	}
+	s.unhealthyUntil[endpoint] = s.clock.Now().Add(s.cooldown)
+}
+
+// markHealthy ends the cooldown of the endpoint.
+func (s *endpointSelector) markHealthy(endpoint string) {
+	if endpoint == "" || len(s.endpoints) == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.unhealthyUntil[endpoint]; ok {
+		logging.Infof("The CAM endpoint %s has recovered", endpoint)
+		delete(s.unhealthyUntil, endpoint)
+	}
+}
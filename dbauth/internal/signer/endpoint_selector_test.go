@@ -0,0 +1,66 @@
+package signer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+func TestEndpointSelector_NoEndpoints(t *testing.T) {
+	selector := newEndpointSelector(nil, time.Minute, dbauthtest.NewFakeClock(time.Now()))
+	assert.Equal(t, []string{""}, selector.candidates("ap-guangzhou"))
+}
+
+func TestEndpointSelector_ReplacesRegion(t *testing.T) {
+	selector := newEndpointSelector([]string{model.EndpointRegional}, time.Minute,
+		dbauthtest.NewFakeClock(time.Now()))
+	assert.Equal(t, []string{"cam.ap-guangzhou.tencentcloudapi.com"}, selector.candidates("ap-guangzhou"))
+}
+
+func TestEndpointSelector_FailsOverAndBack(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	selector := newEndpointSelector([]string{model.EndpointInternal, model.EndpointGlobal}, time.Minute, fakeClock)
+
+	selector.markUnhealthy(model.EndpointInternal)
+	assert.Equal(t, model.EndpointGlobal, selector.candidates("ap-guangzhou")[0])
+
+	fakeClock.Advance(time.Minute)
+	assert.Equal(t, model.EndpointInternal, selector.candidates("ap-guangzhou")[0])
+}
+
+func TestEndpointSelector_MarkHealthyEndsCooldown(t *testing.T) {
+	selector := newEndpointSelector([]string{model.EndpointInternal, model.EndpointGlobal}, time.Minute,
+		dbauthtest.NewFakeClock(time.Now()))
+
+	selector.markUnhealthy(model.EndpointInternal)
+	selector.markHealthy(model.EndpointInternal)
+	assert.Equal(t, model.EndpointInternal, selector.candidates("ap-guangzhou")[0])
+}
+
+func TestEndpointSelector_AllUnhealthy(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	selector := newEndpointSelector([]string{model.EndpointInternal, model.EndpointGlobal}, time.Minute, fakeClock)
+
+	selector.markUnhealthy(model.EndpointInternal)
+	fakeClock.Advance(time.Second)
+	selector.markUnhealthy(model.EndpointGlobal)
+
+	// The endpoint whose cooldown ends first is used.
+	assert.Equal(t, model.EndpointInternal, selector.candidates("ap-guangzhou")[0])
+}
+
+func TestEndpointSelector_Candidates(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Now())
+	selector := newEndpointSelector([]string{model.EndpointInternal, model.EndpointRegional, model.EndpointGlobal},
+		time.Minute, fakeClock)
+
+	selector.markUnhealthy(model.EndpointGlobal)
+	fakeClock.Advance(time.Second)
+	selector.markUnhealthy(model.EndpointInternal)
+
+	assert.Equal(t, []string{"cam.ap-guangzhou.tencentcloudapi.com", model.EndpointGlobal, model.EndpointInternal},
+		selector.candidates("ap-guangzhou"))
+}
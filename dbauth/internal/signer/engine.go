@@ -0,0 +1,379 @@
+package signer
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/fallback"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/breaker"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/errorcode"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/ratelimit"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/timer"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/token"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/tokenstore"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+)
+
+// Engine holds the options, the token cache and the refresh timers shared by the signers of a client.
+type Engine struct {
+	options      model.ClientOptions
+	clock        clock.Clock
+	tokenCache   *token.Cache
+	timerManager *timer.Manager
+	// clientPool holds the CAM clients, which send every CAM request of the engine.
+	clientPool *clientPool
+	// endpoints chooses the CAM endpoint of every CAM request of the engine.
+	endpoints *endpointSelector
+	// fallbackProvider provides the password served when CAM cannot issue a token.
+	fallbackProvider model.FallbackProvider
+	// breakers holds the circuit breaker of every CAM endpoint of the engine, if the circuit breaker is enabled.
+	breakers   map[string]*breaker.Breaker
+	breakersMu sync.Mutex
+	// limiter limits the rate of the CAM requests of the engine, if not nil.
+	limiter *ratelimit.Limiter
+	// bulkhead limits the number of concurrent CAM requests of the engine, if not nil.
+	bulkhead *ratelimit.Bulkhead
+	// tokenStore persists the tokens issued by CAM, if not nil.
+	tokenStore *tokenstore.Store
+	// stopFallbackWatch stops watching the fallback provider for changed passwords, if not nil.
+	stopFallbackWatch func()
+	// restoredKeys holds the auth keys whose persisted token has been looked up.
+	restoredKeys sync.Map
+	// clockSkew is the latest estimate in nanoseconds of the offset of the CAM clock from the local clock.
+	clockSkew int64
+}
+
+// NewEngine creates a new Engine with the provided client options.
+func NewEngine(options model.ClientOptions) *Engine {
+	engineClock := options.Clock
+	if engineClock == nil {
+		engineClock = clock.System
+	}
+	fallbackProvider := options.FallbackProvider
+	if fallbackProvider == nil {
+		fallbackProvider = fallback.NewWorkingDirectoryProvider().WithClock(engineClock)
+	}
+	engine := &Engine{
+		options:          options,
+		fallbackProvider: fallbackProvider,
+		clock:            engineClock,
+		tokenCache:       token.NewTokenCache(options.PreviousTokenOverlap, engineClock),
+		timerManager:     timer.NewManagerWithClock(engineClock),
+		clientPool:       newClientPool(newTransport(options)),
+		endpoints:        newEndpointSelector(options.Endpoints, options.EndpointCooldown, engineClock),
+	}
+	if options.RateLimit > 0 {
+		engine.limiter = ratelimit.NewLimiter(options.RateLimit, options.RateLimitBurst, engineClock)
+	}
+	if options.MaxConcurrentRequests > 0 {
+		engine.bulkhead = ratelimit.NewBulkhead(options.MaxConcurrentRequests)
+	}
+	if options.TokenStoreDir != "" {
+		engine.tokenStore = tokenstore.NewStore(options.TokenStoreDir, options.TokenStoreKey)
+	}
+	if watcher, ok := fallbackProvider.(model.FallbackWatcher); ok && options.FallbackPollInterval > 0 {
+		engine.stopFallbackWatch = watcher.WatchFallback(options.FallbackPollInterval, engineClock,
+			engine.fallbackChanged)
+	}
+	return engine
+}
+
+// newTransport returns the transport of the CAM requests, which applies the proxy URL and the root CAs of the
+// options to a copy of their transport, or of http.DefaultTransport. The options must have been validated.
+func newTransport(options model.ClientOptions) http.RoundTripper {
+	if options.ProxyURL == "" && options.RootCAs == nil {
+		return options.Transport
+	}
+
+	base, ok := options.Transport.(*http.Transport)
+	if !ok {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+	if options.ProxyURL != "" {
+		proxyURL, err := url.Parse(options.ProxyURL)
+		if err != nil {
+			logging.Errorf("Failed to parse the proxy URL, error: %v", err)
+		} else {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	if options.RootCAs != nil {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.RootCAs = options.RootCAs
+	}
+	return transport
+}
+
+// acquire waits until a CAM request can be sent under the rate limit and the concurrency limit of the engine, and
+// returns the function which must be called once the request is done.
+func (e *Engine) acquire() func() {
+	if e.limiter == nil && e.bulkhead == nil {
+		return func() {}
+	}
+
+	start := e.clock.Now()
+	if e.limiter != nil {
+		e.limiter.Wait()
+	}
+	if e.bulkhead != nil {
+		e.bulkhead.Acquire()
+	}
+	if metrics := e.options.Metrics; metrics != nil {
+		metrics.ObserveDuration(model.MetricCamQueueDelay, e.clock.Now().Sub(start), nil)
+	}
+
+	if e.bulkhead == nil {
+		return func() {}
+	}
+	return e.bulkhead.Release
+}
+
+// allowRequest checks whether the circuit breaker of the endpoint lets a CAM request through. A request it allows
+// must be followed by a call to recordBreakerResult.
+func (e *Engine) allowRequest(endpoint string) bool {
+	if endpointBreaker := e.breaker(endpoint); endpointBreaker != nil {
+		return endpointBreaker.Allow()
+	}
+	return true
+}
+
+// recordBreakerResult records the result of a CAM request to the endpoint in its circuit breaker. Only the network
+// errors count as failures, since any answer of CAM shows that it is reachable.
+func (e *Engine) recordBreakerResult(endpoint string, err error) {
+	endpointBreaker := e.breaker(endpoint)
+	if endpointBreaker == nil {
+		return
+	}
+	if errorcode.IsNetworkError(err) {
+		endpointBreaker.Failure()
+	} else {
+		endpointBreaker.Success()
+	}
+}
+
+// breaker returns the circuit breaker of the endpoint, creating it if needed, or nil if the circuit breaker is
+// disabled.
+func (e *Engine) breaker(endpoint string) *breaker.Breaker {
+	if e.options.CircuitBreakerThreshold == 0 {
+		return nil
+	}
+
+	e.breakersMu.Lock()
+	defer e.breakersMu.Unlock()
+	if e.breakers == nil {
+		e.breakers = map[string]*breaker.Breaker{}
+	}
+	endpointBreaker, ok := e.breakers[endpoint]
+	if !ok {
+		endpointBreaker = breaker.NewBreaker(e.options.CircuitBreakerThreshold, e.options.CircuitBreakerOpenDuration,
+			e.clock, func(from, to model.CircuitState) {
+				e.circuitStateChanged(endpoint, from, to)
+			})
+		e.breakers[endpoint] = endpointBreaker
+	}
+	return endpointBreaker
+}
+
+// circuitStateChanged reports a state change of the circuit breaker of the endpoint.
+func (e *Engine) circuitStateChanged(endpoint string, from, to model.CircuitState) {
+	if to == model.CircuitOpen {
+		logging.Warnf("The circuit breaker of the CAM endpoint %s is open for %v", endpoint,
+			e.options.CircuitBreakerOpenDuration)
+	} else {
+		logging.Infof("The circuit breaker of the CAM endpoint %s is %s", endpoint, to)
+	}
+
+	if metrics := e.options.Metrics; metrics != nil {
+		metrics.SetGauge(model.MetricCamCircuitState, float64(to), map[string]string{"endpoint": endpoint})
+		metrics.IncCounter(model.MetricCamCircuitTransitions,
+			map[string]string{"endpoint": endpoint, "state": to.String()})
+	}
+	if listener := e.options.EventListener; listener != nil {
+		listener.OnEvent(model.Event{
+			Type:                 model.EventCircuitStateChanged,
+			Time:                 e.clock.Now(),
+			Endpoint:             endpoint,
+			CircuitState:         to,
+			PreviousCircuitState: from,
+		})
+	}
+}
+
+// recordRateLimitResult adapts the rate limiter of the engine to the result of a CAM request.
+func (e *Engine) recordRateLimitResult(err error) {
+	throttled := errorcode.IsRateLimitError(err)
+	if throttled {
+		if metrics := e.options.Metrics; metrics != nil {
+			metrics.IncCounter(model.MetricCamThrottled, nil)
+		}
+	}
+	if e.limiter == nil {
+		return
+	}
+
+	if throttled {
+		e.limiter.Throttled()
+		logging.Warnf("CAM throttled the request, lowering the rate limit to %.2f requests per second",
+			e.limiter.Rate())
+	} else if err == nil {
+		e.limiter.Succeeded()
+	} else {
+		return
+	}
+	if metrics := e.options.Metrics; metrics != nil {
+		metrics.SetGauge(model.MetricCamRateLimit, e.limiter.Rate(), nil)
+	}
+}
+
+// fallbackToken returns the fallback token of the request served after CAM failed with camErr, provided by the
+// fallback provider of the engine, or nil if it has none. It expires after the fallback TTL, or at the expiry of the
+// password if sooner.
+func (e *Engine) fallbackToken(request *model.GenerateAuthenticationTokenRequest, camErr error) *token.Token {
+	password, err := e.fallbackProvider.FallbackPassword(request)
+	if err != nil {
+		logging.Errorf("Failed to get the fallback password, error: %v", err)
+		return nil
+	}
+	if password == nil {
+		return nil
+	}
+
+	now := e.clock.Now()
+	expiry := now.Add(e.fallbackTTL())
+	if !password.Expiry.IsZero() && password.Expiry.Before(expiry) {
+		expiry = password.Expiry
+	}
+	logging.Infof("Reading the password from %s", password.Source)
+	return token.NewFallbackToken(password.Value, expiry, &token.FallbackInfo{
+		Source:    password.Source,
+		Metadata:  password.Metadata,
+		CheckedAt: now,
+		Err:       camErr,
+	})
+}
+
+// fallbackChanged drops the cached fallback tokens read from the source after its fallback password changed, so
+// that the new password is read on the next token request or background refresh.
+func (e *Engine) fallbackChanged(source string) {
+	removed := e.tokenCache.RemoveFallbackTokens(source)
+	logging.Infof("The fallback password %s changed, dropped %d cached fallback tokens", source, removed)
+	if listener := e.options.EventListener; listener != nil {
+		listener.OnEvent(model.Event{
+			Type:   model.EventFallbackChanged,
+			Time:   e.clock.Now(),
+			Source: source,
+		})
+	}
+}
+
+// fallbackTTL returns how long a fallback password is served before it is read again.
+func (e *Engine) fallbackTTL() time.Duration {
+	if e.options.FallbackTTL > 0 {
+		return e.options.FallbackTTL
+	}
+	return constants.MaxDelay * time.Millisecond
+}
+
+// fallbackRecheckInterval returns how often CAM is asked again for a token while a fallback token is served.
+func (e *Engine) fallbackRecheckInterval() time.Duration {
+	if e.options.FallbackRecheckInterval > 0 {
+		return e.options.FallbackRecheckInterval
+	}
+	return tokenUpdateInterval * time.Millisecond
+}
+
+// restoreToken returns the persisted token of the auth key if it is still valid. The persisted token of an auth key
+// is only looked up once, before the first token of the auth key is cached.
+func (e *Engine) restoreToken(authKey string) *token.Token {
+	if e.tokenStore == nil {
+		return nil
+	}
+	if _, looked := e.restoredKeys.LoadOrStore(authKey, true); looked {
+		return nil
+	}
+	restored, err := e.tokenStore.Load(authKey, e.clock.Now())
+	if err != nil {
+		logging.Warnf("Failed to load the persisted token, error: %v", err)
+		return nil
+	}
+	if restored != nil {
+		logging.Infof("Restored the persisted token, expiry: %s",
+			restored.GetExpiresAt().Format("2006-01-02 15:04:05"))
+	}
+	return restored
+}
+
+// persistToken persists the token of the auth key, if the token store is enabled and the token was issued by CAM.
+func (e *Engine) persistToken(authKey string, authToken *token.Token) {
+	if e.tokenStore == nil || authToken.IsFallback() {
+		return
+	}
+	e.restoredKeys.Store(authKey, true)
+	if err := e.tokenStore.Save(authKey, authToken); err != nil {
+		logging.Warnf("Failed to persist the token, error: %v", err)
+	}
+}
+
+// removePersistedToken removes the persisted token of the auth key, if the token store is enabled.
+func (e *Engine) removePersistedToken(authKey string) {
+	if e.tokenStore == nil {
+		return
+	}
+	if err := e.tokenStore.Remove(authKey); err != nil {
+		logging.Warnf("Failed to remove the persisted token, error: %v", err)
+	}
+}
+
+// Close stops the background refreshes and the watch of the fallback provider of the engine, and closes the
+// fallback provider if it implements io.Closer.
+func (e *Engine) Close() {
+	e.timerManager.Close()
+	if e.stopFallbackWatch != nil {
+		e.stopFallbackWatch()
+	}
+	if closer, ok := e.fallbackProvider.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logging.Errorf("Failed to close the fallback provider, error: %v", err)
+		}
+	}
+}
+
+// Now returns the current time of the clock of the engine.
+func (e *Engine) Now() time.Time {
+	return e.clock.Now()
+}
+
+// New creates a new Signer with the provided token request.
+func (e *Engine) New(request model.GenerateAuthenticationTokenRequest) *Signer {
+	key := request.Region() + constants.DELIMITER + request.InstanceId() + constants.DELIMITER +
+		request.UserName() + constants.DELIMITER + request.Credential().GetSecretId()
+	authKey := base64.StdEncoding.EncodeToString([]byte(key))
+	return &Signer{authKey: authKey, request: request, engine: e}
+}
+
+// ClockSkew returns the latest estimate of the offset of the CAM clock from the local clock.
+// It is positive when the CAM clock is ahead, and zero until a token has been issued by CAM.
+func (e *Engine) ClockSkew() time.Duration {
+	return time.Duration(atomic.LoadInt64(&e.clockSkew))
+}
+
+// updateClockSkew records a new clock skew estimate and warns when it exceeds the warning threshold.
+func (e *Engine) updateClockSkew(clockSkew time.Duration) {
+	atomic.StoreInt64(&e.clockSkew, int64(clockSkew))
+
+	threshold := e.options.ClockSkewWarningThreshold
+	if threshold > 0 && (clockSkew > threshold || clockSkew < -threshold) {
+		logging.Warnf("The local clock is off by %v from the CAM clock, which exceeds %v", -clockSkew, threshold)
+	}
+}
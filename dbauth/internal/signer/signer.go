@@ -2,48 +2,61 @@
 package signer
 
 import (
-	"encoding/base64"
 	"fmt"
+	"math/rand"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/errorcode"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/parser"
-	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/timer"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/token"
-	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/utils"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
 	cam "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
 )
 
-const tokenUpdateInterval = 5000
-
-var (
-	tokenCache   = token.NewTokenCache()
-	timerManager = timer.NewManager()
-	logging      = logrus.WithField("component", "signer")
+const (
+	tokenUpdateInterval = 5000
+	// retryInterval is the delay in milliseconds before a failed token update is retried.
+	retryInterval = tokenUpdateInterval
+	// refreshJitterPercent is the maximum share of the proactive refresh delay removed at random,
+	// so that tokens issued together are not refreshed together.
+	refreshJitterPercent = 10
+	// maxCreateTimeDrift is how far in milliseconds the CAM server time may be before the token creation time.
+	maxCreateTimeDrift = 60000
+	// secondsThreshold separates creation times in seconds from creation times in milliseconds.
+	secondsThreshold = 100000000000
 )
 
+var logging = logrus.WithField("component", "signer")
+
 // Signer represents the authentication token generation logic.
 type Signer struct {
 	authKey string
 	request model.GenerateAuthenticationTokenRequest
+	engine  *Engine
 }
 
-// New creates a new Signer with the provided token request.
-func New(request model.GenerateAuthenticationTokenRequest) *Signer {
-	key := request.Region() + constants.DELIMITER + request.InstanceId() + constants.DELIMITER +
-		request.UserName() + constants.DELIMITER + request.Credential().GetSecretId()
-	authKey := base64.StdEncoding.EncodeToString([]byte(key))
-	return &Signer{authKey: authKey, request: request}
+// GetAuthTokenFromCache gets the authentication token from the cache. Before the first token of the request is
+// cached, the token persisted by a previous process is restored if it is still valid.
+func (s *Signer) GetAuthTokenFromCache() *token.Token {
+	if cachedToken := s.engine.tokenCache.GetAuthToken(s.authKey); cachedToken != nil {
+		return cachedToken
+	}
+	if restoredToken := s.engine.restoreToken(s.authKey); restoredToken != nil {
+		s.setTokenAndUpdateTask(restoredToken)
+		return restoredToken
+	}
+	return nil
 }
 
-// GetAuthTokenFromCache gets the authentication token from the cache.
-func (s *Signer) GetAuthTokenFromCache() *token.Token {
-	return tokenCache.GetAuthToken(s.authKey)
+// GetPreviousAuthTokenFromCache gets the authentication token replaced by the cached one while it is still
+// within the overlap period, and the time until which it is kept.
+func (s *Signer) GetPreviousAuthTokenFromCache() (*token.Token, time.Time) {
+	return s.engine.tokenCache.GetPreviousAuthToken(s.authKey)
 }
 
 // BuildAuthToken generates the authentication token.
@@ -54,19 +67,24 @@ func (s *Signer) BuildAuthToken() error {
 	authToken, err := s.getAuthToken()
 	if err == nil {
 		logging.Debugf("Successfully get the authentication token, expiry: %s",
-			time.Unix(authToken.GetExpires()/1000, 0).Format("2006-01-02 15:04:05"))
+			authToken.GetExpiresAt().Format("2006-01-02 15:04:05"))
+		if cachedToken := s.engine.tokenCache.GetAuthToken(s.authKey); cachedToken != nil && cachedToken.IsFallback() {
+			logging.Infof("CAM issued a token, replacing the fallback token")
+		}
 
 		s.setTokenAndUpdateTask(authToken)
+		s.engine.persistToken(s.authKey, authToken)
 		return nil
 	}
 
-	// 2. If the error code requires user notification, return the error
-	if errorcode.IsUserNotificationRequired(err) {
+	// 2. If the fallback policy does not allow the fallback, such as for the errors which require user
+	// notification by default, return the error
+	if !s.FallbackPolicy().AllowFallback(&s.request, err) {
 		return err
 	}
 
 	// 3. If the token generation fails, use the fallback token
-	fallbackToken := tokenCache.Fallback(&s.request)
+	fallbackToken := s.engine.fallbackToken(&s.request, err)
 	if fallbackToken != nil {
 		logging.Infof("Using the fallback token")
 		s.setTokenAndUpdateTask(fallbackToken)
@@ -77,13 +95,49 @@ func (s *Signer) BuildAuthToken() error {
 	}
 }
 
+// FallbackPolicy returns the fallback policy of the request, or else of the client.
+func (s *Signer) FallbackPolicy() model.FallbackPolicy {
+	if policy := s.request.FallbackPolicy(); policy != nil {
+		return policy
+	}
+	if policy := s.engine.options.FallbackPolicy; policy != nil {
+		return policy
+	}
+	return model.FallbackOnRetryableErrors
+}
+
 func (s *Signer) setTokenAndUpdateTask(token *token.Token) {
-	tokenCache.SetAuthToken(s.authKey, token)
-	s.updateAuthTokenTask(token.GetExpires())
+	s.engine.tokenCache.SetAuthToken(s.authKey, token)
+	if s.isRefreshScheduled() {
+		s.updateAuthTokenTask(int64(token.Remaining(s.engine.Now())/time.Millisecond), token.IsFallback())
+	}
+}
+
+// IsFallbackRecheckDue reports whether CAM must be asked again for a token before the cached token is served: the
+// cached token is a fallback token which has been served for the fallback recheck interval, and it is not
+// refreshed in the background.
+func (s *Signer) IsFallbackRecheckDue(cachedToken *token.Token) bool {
+	info := cachedToken.GetFallbackInfo()
+	if info == nil || s.isRefreshScheduled() {
+		return false
+	}
+	return s.engine.Now().Sub(info.CheckedAt) >= s.engine.fallbackRecheckInterval()
+}
+
+// isRefreshScheduled reports whether the token is refreshed in the background under the client refresh mode.
+func (s *Signer) isRefreshScheduled() bool {
+	switch s.engine.options.RefreshMode {
+	case model.RefreshModeLazy:
+		return false
+	case model.RefreshModeHybrid:
+		return s.request.IsHot()
+	default:
+		return true
+	}
 }
 
 func (s *Signer) getAuthToken() (*token.Token, error) {
-	response, err := s.requestAuthToken()
+	response, sentAt, receivedAt, err := s.requestAuthToken()
 	if err != nil {
 		return nil, err
 	}
@@ -100,17 +154,33 @@ func (s *Signer) getAuthToken() (*token.Token, error) {
 			fmt.Sprintf("Failed to request AuthToken, requestId: %v, tokenResponse is null", requestId),
 			cam.INTERNALERROR, requestId)
 	}
+	if tokenResponse.CurrentTime == nil || tokenResponse.NextRotationTime == nil {
+		return nil, s.logAndReturnError(
+			fmt.Sprintf("Failed to request AuthToken, requestId: %v, rotation time is null", requestId),
+			cam.INTERNALERROR, requestId)
+	}
 
 	// Decrypt the authToken
-	authToken, err := s.decryptAuthToken(*tokenResponse.Token)
-	if err != nil || authToken == "" {
+	tokenInfo, err := s.decryptAuthToken(*tokenResponse.Token)
+	if err != nil || tokenInfo.Password == "" {
 		return nil, s.logAndReturnError(fmt.Sprintf("Failed to decrypt AuthToken, requestId: %v, error: %v",
 			requestId, err), cam.INTERNALERROR, requestId)
 	}
 
+	// Estimate the offset of the CAM clock, which answered about halfway through the request
+	clockSkew := estimateClockSkew(*tokenResponse.CurrentTime, sentAt, receivedAt)
+	s.engine.updateClockSkew(clockSkew)
+
 	// Calculate the expiry time of the authToken
-	expiry := s.calculateExpiry(*tokenResponse.CurrentTime, *tokenResponse.NextRotationTime)
-	return token.NewToken(authToken, expiry), nil
+	expiry := calculateExpiry(receivedAt, *tokenResponse.CurrentTime, *tokenResponse.NextRotationTime,
+		tokenInfo.CreateTime)
+	return token.NewCamToken(tokenInfo.Password, expiry, &token.Metadata{
+		RequestId:        requestId,
+		CurrentTime:      *tokenResponse.CurrentTime,
+		NextRotationTime: *tokenResponse.NextRotationTime,
+		TokenInfo:        tokenInfo,
+		ClockSkew:        clockSkew,
+	}), nil
 }
 
 func (s *Signer) logAndReturnError(message, code, requestId string) error {
@@ -118,23 +188,46 @@ func (s *Signer) logAndReturnError(message, code, requestId string) error {
 	return errors.NewTencentCloudSDKError(code, message, requestId)
 }
 
-func (s *Signer) decryptAuthToken(encAuthToken string) (string, error) {
+func (s *Signer) decryptAuthToken(encAuthToken string) (*pb.AuthTokenInfo, error) {
 	instanceId, region, userName := s.request.InstanceId(), s.request.Region(), s.request.UserName()
-	tokenInfo, err := parser.ParseAuthToken(instanceId, region, userName, encAuthToken)
-	if err != nil {
-		return "", err
+	return parser.ParseAuthToken(instanceId, region, userName, encAuthToken)
+}
+
+// calculateExpiry returns the expiry of a token received at receivedAt. The lifetime is the time left until the
+// next rotation by the CAM clock, and is added to receivedAt so that it is measured on the monotonic clock.
+// If the CAM time is before the creation time embedded in the token, the creation time is used instead.
+func calculateExpiry(receivedAt time.Time, camServerTime, authTokenExpires int64, createTime uint64) time.Time {
+	serverTime := camServerTime
+	if createTimeMillis := normalizeCreateTime(createTime); createTimeMillis-camServerTime > maxCreateTimeDrift {
+		logging.Warnf("The CAM server time %d is %d ms before the token create time %d, using the create time",
+			camServerTime, createTimeMillis-camServerTime, createTimeMillis)
+		serverTime = createTimeMillis
+	}
+
+	if authTokenExpires < serverTime {
+		return receivedAt.Add(tokenUpdateInterval * time.Millisecond)
 	}
-	return tokenInfo.Password, nil
+	return receivedAt.Add(time.Duration(authTokenExpires-serverTime) * time.Millisecond)
 }
 
-func (s *Signer) calculateExpiry(camServerTime, authTokenExpires int64) int64 {
-	if authTokenExpires < camServerTime {
-		return utils.GetCurrentTimeMillis() + tokenUpdateInterval
+// normalizeCreateTime returns the token creation time in milliseconds, which may be embedded in seconds.
+func normalizeCreateTime(createTime uint64) int64 {
+	if createTime < secondsThreshold {
+		return int64(createTime) * 1000
 	}
-	return utils.GetCurrentTimeMillis() + (authTokenExpires - camServerTime)
+	return int64(createTime)
 }
 
-func (s *Signer) requestAuthToken() (*cam.BuildDataFlowAuthTokenResponse, error) {
+// estimateClockSkew returns the offset of the CAM clock from the local wall clock, assuming CAM answered
+// halfway between sentAt and receivedAt.
+func estimateClockSkew(camServerTime int64, sentAt, receivedAt time.Time) time.Duration {
+	midpoint := sentAt.Add(receivedAt.Sub(sentAt) / 2)
+	return time.Duration(camServerTime)*time.Millisecond - time.Duration(midpoint.UnixNano())
+}
+
+// requestAuthToken requests the authentication token from CAM, and returns the time the successful attempt
+// was sent and its response received.
+func (s *Signer) requestAuthToken() (*cam.BuildDataFlowAuthTokenResponse, time.Time, time.Time, error) {
 	clientProfile := s.request.ClientProfile()
 	if clientProfile == nil {
 		clientProfile = profile.NewClientProfile()
@@ -142,23 +235,42 @@ func (s *Signer) requestAuthToken() (*cam.BuildDataFlowAuthTokenResponse, error)
 		clientProfile.HttpProfile.ReqTimeout = 30 // Set the request timeout to 30 seconds
 	}
 
-	client, err := cam.NewClient(s.request.Credential(), s.request.Region(), clientProfile)
-	if err != nil {
-		return nil, errors.NewTencentCloudSDKError(cam.INTERNALERROR,
-			fmt.Sprintf("Failed to create the client, error: %v", err), "")
-	}
-
 	resourceId, region, userName := s.request.InstanceId(), s.request.Region(), s.request.UserName()
-	req := cam.NewBuildDataFlowAuthTokenRequest()
-	req.ResourceId = &resourceId
-	req.ResourceRegion = &region
-	req.ResourceAccount = &userName
 
 	var lastErr error
 	for i := 0; i < 3; i++ {
+		// The request keeps the domain of the endpoint it is first sent to, so every attempt gets a new one.
+		req := cam.NewBuildDataFlowAuthTokenRequest()
+		req.ResourceId = &resourceId
+		req.ResourceRegion = &region
+		req.ResourceAccount = &userName
+
+		endpoint, client, err := s.nextEndpoint(region, clientProfile)
+		if err != nil {
+			return nil, time.Time{}, time.Time{}, errors.NewTencentCloudSDKError(cam.INTERNALERROR,
+				fmt.Sprintf("Failed to create the client, error: %v", err), "")
+		}
+		if client == nil {
+			// Every circuit is open, so the token is served from the cache, under stale grace or from the fallback.
+			logging.Warnf("The circuit breaker of every CAM endpoint is open, skipping the request")
+			lastErr = errors.NewTencentCloudSDKError(errorcode.ErrorCircuitOpen,
+				"The circuit breaker of every CAM endpoint is open.", "")
+			break
+		}
+
+		release := s.engine.acquire()
+		sentAt := s.engine.Now()
 		resp, err := client.BuildDataFlowAuthToken(req)
+		release()
+		s.engine.recordBreakerResult(endpoint, err)
+		s.engine.recordRateLimitResult(err)
 		if err == nil {
-			return resp, nil
+			s.engine.endpoints.markHealthy(endpoint)
+			return resp, sentAt, s.engine.Now(), nil
+		}
+		if errorcode.IsNetworkError(err) {
+			// Fail over to the next endpoint on the next attempt.
+			s.engine.endpoints.markUnhealthy(endpoint)
 		}
 
 		if tcErr, ok := err.(*errors.TencentCloudSDKError); ok {
@@ -174,33 +286,96 @@ func (s *Signer) requestAuthToken() (*cam.BuildDataFlowAuthTokenResponse, error)
 				fmt.Sprintf("Failed to request AuthToken, error: %v", err), "")
 		}
 	}
-	return nil, lastErr
+	return nil, time.Time{}, time.Time{}, lastErr
+}
+
+// nextEndpoint returns the first endpoint of the region whose circuit breaker lets a CAM request through, and its
+// CAM client. It returns a nil client if the circuit breaker of every endpoint is open.
+func (s *Signer) nextEndpoint(region string, clientProfile *profile.ClientProfile) (string, *cam.Client, error) {
+	for _, endpoint := range s.engine.endpoints.candidates(region) {
+		endpointProfile := withEndpoint(clientProfile, endpoint)
+		client, err := s.engine.clientPool.get(s.request.Credential(), region, endpointProfile)
+		if err != nil {
+			return "", nil, err
+		}
+		if endpoint == "" && endpointProfile.HttpProfile != nil {
+			endpoint = endpointProfile.HttpProfile.Endpoint
+		}
+		if s.engine.allowRequest(endpoint) {
+			return endpoint, client, nil
+		}
+	}
+	return "", nil, nil
 }
 
-func (s *Signer) updateAuthTokenTask(authTokenExpiry int64) {
-	// Calculate the remaining time before the token expires
-	remainingTimeBeforeExpiry := authTokenExpiry - utils.GetCurrentTimeMillis()
-	// Get the delay for the next token update
+// withEndpoint returns a copy of the client profile with the provided endpoint, or the client profile itself if
+// the endpoint is empty.
+func withEndpoint(clientProfile *profile.ClientProfile, endpoint string) *profile.ClientProfile {
+	if endpoint == "" {
+		return clientProfile
+	}
+	endpointProfile := *clientProfile
+	httpProfile := profile.NewHttpProfile()
+	if clientProfile.HttpProfile != nil {
+		*httpProfile = *clientProfile.HttpProfile
+	}
+	httpProfile.Endpoint = endpoint
+	endpointProfile.HttpProfile = httpProfile
+	return &endpointProfile
+}
+
+func (s *Signer) updateAuthTokenTask(remainingTimeBeforeExpiry int64, fallback bool) {
+	// Get the delay for the next token update. A fallback token is replaced as soon as CAM recovers,
+	// so it is checked at the fallback recheck interval instead of late in its lifetime.
 	delayForNextTokenUpdate := remainingTimeBeforeExpiry
-	if delayForNextTokenUpdate > tokenUpdateInterval {
-		delayForNextTokenUpdate = tokenUpdateInterval
+	if fallback {
+		recheckInterval := int64(s.engine.fallbackRecheckInterval() / time.Millisecond)
+		if delayForNextTokenUpdate > recheckInterval {
+			delayForNextTokenUpdate = recheckInterval
+		}
+	} else {
+		delayForNextTokenUpdate = nextRefreshDelay(remainingTimeBeforeExpiry)
 	}
 
+	s.scheduleTokenUpdate(delayForNextTokenUpdate)
+}
+
+// scheduleTokenUpdate schedules the next token update after the given delay in milliseconds.
+func (s *Signer) scheduleTokenUpdate(delayForNextTokenUpdate int64) {
 	logging.Debugf("Scheduling next token key update in %v ms", delayForNextTokenUpdate)
 
 	// Save the timer for the next token update
-	timerManager.SaveTimer(s.authKey, delayForNextTokenUpdate, func() {
+	s.engine.timerManager.SaveTimer(s.authKey, delayForNextTokenUpdate, func() {
 		err := s.BuildAuthToken()
 		if err != nil {
 			if errorcode.IsUserNotificationRequired(err) {
 				// If a user notification is required, remove the token from the cache
 				logging.Errorf("Failed to update the authentication token, error: %v", err)
-				tokenCache.RemoveAuthToken(s.authKey)
+				s.engine.tokenCache.RemoveAuthToken(s.authKey)
+				s.engine.removePersistedToken(s.authKey)
 				return
 			}
 			// If an internal error occurs, try to update the token again
 			logging.Errorf("Failed to update the authentication token, Retry to update the token, error: %v", err)
-			s.updateAuthTokenTask(utils.GetCurrentTimeMillis() + tokenUpdateInterval)
+			s.scheduleTokenUpdate(retryInterval)
 		}
 	})
 }
+
+// nextRefreshDelay returns the delay in milliseconds before a token with the given remaining lifetime is
+// refreshed. The token is refreshed after about three quarters of its remaining lifetime, but never sooner
+// than tokenUpdateInterval unless it expires before that.
+func nextRefreshDelay(remainingTimeBeforeExpiry int64) int64 {
+	if remainingTimeBeforeExpiry <= tokenUpdateInterval {
+		return remainingTimeBeforeExpiry
+	}
+
+	delay := remainingTimeBeforeExpiry * 3 / 4
+	if jitter := delay * refreshJitterPercent / 100; jitter > 0 {
+		delay -= rand.Int63n(jitter + 1)
+	}
+	if delay < tokenUpdateInterval {
+		delay = tokenUpdateInterval
+	}
+	return delay
+}
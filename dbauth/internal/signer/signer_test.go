@@ -0,0 +1,88 @@
+package signer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+)
+
+func newTestSigner(t *testing.T, mode model.RefreshMode, hot bool) *Signer {
+	request, err := model.NewGenerateAuthenticationTokenRequest("ap-guangzhou", "cdb-123456", "camtest",
+		common.NewCredential("secretId", "secretKey"), nil)
+	assert.NoError(t, err)
+	request.SetHot(hot)
+
+	options := model.NewClientOptions()
+	options.RefreshMode = mode
+	return NewEngine(*options).New(*request)
+}
+
+func TestNextRefreshDelay_ShortLifetime(t *testing.T) {
+	assert.Equal(t, int64(3000), nextRefreshDelay(3000))
+}
+
+func TestNextRefreshDelay_LongLifetime(t *testing.T) {
+	delay := nextRefreshDelay(600000)
+	assert.LessOrEqual(t, delay, int64(450000))
+	assert.GreaterOrEqual(t, delay, int64(405000))
+}
+
+func TestNextRefreshDelay_NeverBelowInterval(t *testing.T) {
+	assert.Equal(t, int64(tokenUpdateInterval), nextRefreshDelay(tokenUpdateInterval+1))
+}
+
+func TestIsRefreshScheduled_Proactive(t *testing.T) {
+	assert.True(t, newTestSigner(t, model.RefreshModeProactive, false).isRefreshScheduled())
+}
+
+func TestIsRefreshScheduled_Lazy(t *testing.T) {
+	assert.False(t, newTestSigner(t, model.RefreshModeLazy, true).isRefreshScheduled())
+}
+
+func TestIsRefreshScheduled_Hybrid(t *testing.T) {
+	assert.False(t, newTestSigner(t, model.RefreshModeHybrid, false).isRefreshScheduled())
+	assert.True(t, newTestSigner(t, model.RefreshModeHybrid, true).isRefreshScheduled())
+}
+
+func TestCalculateExpiry_UsesRotationTime(t *testing.T) {
+	receivedAt := time.Now()
+	expiry := calculateExpiry(receivedAt, 1700000000000, 1700000600000, 0)
+	assert.Equal(t, 10*time.Minute, expiry.Sub(receivedAt))
+}
+
+func TestCalculateExpiry_RotationTimePassed(t *testing.T) {
+	receivedAt := time.Now()
+	expiry := calculateExpiry(receivedAt, 1700000600000, 1700000000000, 0)
+	assert.Equal(t, tokenUpdateInterval*time.Millisecond, expiry.Sub(receivedAt))
+}
+
+func TestCalculateExpiry_ServerTimeBeforeCreateTime(t *testing.T) {
+	receivedAt := time.Now()
+	// The CAM time lags the creation time by 5 minutes, so the lifetime is counted from the creation time.
+	expiry := calculateExpiry(receivedAt, 1700000000000, 1700000600000, 1700000300000)
+	assert.Equal(t, 5*time.Minute, expiry.Sub(receivedAt))
+}
+
+func TestCalculateExpiry_CreateTimeInSeconds(t *testing.T) {
+	receivedAt := time.Now()
+	expiry := calculateExpiry(receivedAt, 1700000000000, 1700000600000, 1699999990)
+	assert.Equal(t, 10*time.Minute, expiry.Sub(receivedAt))
+}
+
+func TestCalculateExpiry_MonotonicClock(t *testing.T) {
+	expiry := calculateExpiry(time.Now(), 1700000000000, 1700000600000, 0)
+	// A wall clock step does not change the remaining lifetime measured on the monotonic clock.
+	assert.InDelta(t, float64(10*time.Minute), float64(time.Until(expiry)), float64(time.Second))
+	assert.NotEqual(t, expiry.Round(0), expiry)
+}
+
+func TestEstimateClockSkew(t *testing.T) {
+	sentAt := time.Unix(1700000000, 0)
+	receivedAt := sentAt.Add(200 * time.Millisecond)
+
+	assert.Equal(t, 900*time.Millisecond, estimateClockSkew(1700000001000, sentAt, receivedAt))
+	assert.Equal(t, -100*time.Millisecond, estimateClockSkew(1700000000000, sentAt, receivedAt))
+}
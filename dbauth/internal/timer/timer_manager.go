@@ -5,19 +5,28 @@ import (
 	"sync"
 	"time"
 
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
 )
 
 // Manager represents a timer manager.
 type Manager struct {
-	timers map[string]*time.Timer
+	timers map[string]clock.Timer
 	mu     sync.Mutex
+	clock  clock.Clock
+	closed bool
 }
 
 // NewManager creates a new timer manager.
 func NewManager() *Manager {
+	return NewManagerWithClock(clock.System)
+}
+
+// NewManagerWithClock creates a new timer manager which schedules its timers with the provided clock.
+func NewManagerWithClock(timerClock clock.Clock) *Manager {
 	return &Manager{
-		timers: make(map[string]*time.Timer),
+		timers: make(map[string]clock.Timer),
+		clock:  timerClock,
 	}
 }
 
@@ -30,10 +39,25 @@ func (tm *Manager) SaveTimer(key string, delay int64, task func()) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
+	if tm.closed {
+		return
+	}
 	if timer, exists := tm.timers[key]; exists {
 		timer.Stop()
 		delete(tm.timers, key)
 	}
 
-	tm.timers[key] = time.AfterFunc(time.Duration(delay)*time.Millisecond, task)
+	tm.timers[key] = tm.clock.AfterFunc(time.Duration(delay)*time.Millisecond, task)
+}
+
+// Close stops every timer, and makes SaveTimer ignore the timers saved afterwards.
+func (tm *Manager) Close() {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	tm.closed = true
+	for key, timer := range tm.timers {
+		timer.Stop()
+		delete(tm.timers, key)
+	}
 }
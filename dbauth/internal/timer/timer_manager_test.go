@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
 	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
 )
 
@@ -77,3 +78,49 @@ func TestSaveTimer_OverwriteExistingTimer(t *testing.T) {
 	assert.False(t, taskExecuted1)
 	assert.True(t, taskExecuted2)
 }
+
+func TestSaveTimer_WithFakeClock(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Unix(1700000000, 0))
+	manager := NewManagerWithClock(fakeClock)
+	taskExecuted := false
+	task := func() { taskExecuted = true }
+
+	manager.SaveTimer("validKey", 100, task)
+	fakeClock.Advance(99 * time.Millisecond)
+	assert.False(t, taskExecuted)
+
+	fakeClock.Advance(time.Millisecond)
+	assert.True(t, taskExecuted)
+}
+
+func TestSaveTimer_OverwriteExistingTimerWithFakeClock(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Unix(1700000000, 0))
+	manager := NewManagerWithClock(fakeClock)
+	taskExecuted1 := false
+	task1 := func() { taskExecuted1 = true }
+	taskExecuted2 := false
+	task2 := func() { taskExecuted2 = true }
+
+	manager.SaveTimer("validKey", 200, task1)
+	manager.SaveTimer("validKey", 100, task2)
+	fakeClock.Advance(300 * time.Millisecond)
+
+	assert.False(t, taskExecuted1)
+	assert.True(t, taskExecuted2)
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+}
+
+func TestClose_StopsTimers(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Unix(1700000000, 0))
+	manager := NewManagerWithClock(fakeClock)
+	taskExecuted := false
+	task := func() { taskExecuted = true }
+
+	manager.SaveTimer("validKey", 100, task)
+	manager.Close()
+	manager.SaveTimer("otherKey", 100, task)
+	fakeClock.Advance(time.Second)
+
+	assert.False(t, taskExecuted)
+	assert.Equal(t, 0, fakeClock.PendingTimers())
+}
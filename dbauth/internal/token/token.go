@@ -1,15 +1,59 @@
 // Package token provides structures and functions for managing authentication tokens.
 package token
 
+import (
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
 // Token represents an authentication token with its expiration time.
 type Token struct {
 	authToken string
-	expires   int64
+	// expiresAt carries the monotonic clock reading of the time it was derived from, if any,
+	// so that the remaining lifetime is not affected by wall clock jumps.
+	expiresAt time.Time
+	fallback  bool
+	metadata  *Metadata
+	// fallbackInfo describes the origin of a fallback token, nil for other tokens.
+	fallbackInfo *FallbackInfo
+}
+
+// Metadata represents the details of the CAM response which issued a token.
+type Metadata struct {
+	RequestId        string
+	CurrentTime      int64
+	NextRotationTime int64
+	TokenInfo        *pb.AuthTokenInfo
+	// ClockSkew is the estimated offset of the CAM clock from the local clock when the token was issued.
+	ClockSkew time.Duration
+}
+
+// FallbackInfo represents the origin of a fallback token.
+type FallbackInfo struct {
+	// Source describes where the fallback password was read from.
+	Source string
+	// Metadata is the metadata of the fallback password.
+	Metadata map[string]string
+	// CheckedAt is when CAM last failed to issue a token, which made the fallback password be served.
+	CheckedAt time.Time
+	// Err is the CAM error which made the fallback password be served.
+	Err error
 }
 
 // NewToken creates a new Token with the provided authentication token and expiration time.
-func NewToken(authToken string, expires int64) *Token {
-	return &Token{authToken: authToken, expires: expires}
+func NewToken(authToken string, expiresAt time.Time) *Token {
+	return &Token{authToken: authToken, expiresAt: expiresAt}
+}
+
+// NewCamToken creates a new Token issued by CAM with the provided response metadata.
+func NewCamToken(authToken string, expiresAt time.Time, metadata *Metadata) *Token {
+	return &Token{authToken: authToken, expiresAt: expiresAt, metadata: metadata}
+}
+
+// NewFallbackToken creates a new Token from a fallback password with the provided origin.
+func NewFallbackToken(authToken string, expiresAt time.Time, info *FallbackInfo) *Token {
+	return &Token{authToken: authToken, expiresAt: expiresAt, fallback: true, fallbackInfo: info}
 }
 
 // GetAuthToken returns the authentication token.
@@ -17,7 +61,50 @@ func (t *Token) GetAuthToken() string {
 	return t.authToken
 }
 
-// GetExpires returns the expiration time.
+// GetExpires returns the expiration time in milliseconds.
 func (t *Token) GetExpires() int64 {
-	return t.expires
+	return t.expiresAt.UnixNano() / int64(time.Millisecond)
+}
+
+// GetExpiresAt returns the expiration time.
+func (t *Token) GetExpiresAt() time.Time {
+	return t.expiresAt
+}
+
+// Remaining returns the remaining lifetime of the token at the provided time, measured on the monotonic clock
+// when both times carry a monotonic clock reading.
+func (t *Token) Remaining(now time.Time) time.Duration {
+	return t.expiresAt.Sub(now)
+}
+
+// IsValidFor returns whether the token remains valid for more than the provided duration after now.
+func (t *Token) IsValidFor(now time.Time, duration time.Duration) bool {
+	return t.Remaining(now) > duration
+}
+
+// Lifetime returns the lifetime of the token when it was issued: the time from the CAM response to the next
+// rotation for a token issued by CAM, or the fallback TTL for a fallback token. It is zero if unknown.
+func (t *Token) Lifetime() time.Duration {
+	if t.metadata != nil && t.metadata.NextRotationTime > t.metadata.CurrentTime {
+		return time.Duration(t.metadata.NextRotationTime-t.metadata.CurrentTime) * time.Millisecond
+	}
+	if t.fallbackInfo != nil && t.expiresAt.After(t.fallbackInfo.CheckedAt) {
+		return t.expiresAt.Sub(t.fallbackInfo.CheckedAt)
+	}
+	return 0
+}
+
+// IsFallback returns whether the token is a fallback password.
+func (t *Token) IsFallback() bool {
+	return t.fallback
+}
+
+// GetMetadata returns the metadata of the CAM response which issued the token, nil for other tokens.
+func (t *Token) GetMetadata() *Metadata {
+	return t.metadata
+}
+
+// GetFallbackInfo returns the origin of a fallback token, nil for other tokens.
+func (t *Token) GetFallbackInfo() *FallbackInfo {
+	return t.fallbackInfo
 }
@@ -2,122 +2,105 @@
 package token
 
 import (
-	"bufio"
-	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
+	"time"
 
-	"github.com/sirupsen/logrus"
-	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/constants"
-	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/utils"
-	"github.com/tencentcloud/dbauth-sdk-go/dbauth/model"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
 )
 
-const MaxPasswordSize = 200
-
-var logging = logrus.WithField("component", "token_cache")
-
 // Cache represents a token cache.
 type Cache struct {
 	tokenMap sync.Map
+	// mu serializes the updates of an entry, which replace its current token and keep the previous one.
+	mu sync.Mutex
+	// previousTokenOverlap is how long a replaced token is kept as the previous token.
+	previousTokenOverlap time.Duration
+	clock                clock.Clock
 }
 
-// NewTokenCache creates a new token cache.
-func NewTokenCache() *Cache {
-	return &Cache{}
+// cacheEntry holds the current token of a key and the token it replaced during the overlap period.
+type cacheEntry struct {
+	current       *Token
+	previous      *Token
+	previousUntil time.Time
+}
+
+// NewTokenCache creates a new token cache which keeps a replaced token for previousTokenOverlap,
+// as measured by the provided clock.
+func NewTokenCache(previousTokenOverlap time.Duration, cacheClock clock.Clock) *Cache {
+	return &Cache{previousTokenOverlap: previousTokenOverlap, clock: cacheClock}
 }
 
 // GetAuthToken gets the authentication token from the cache.
 func (tc *Cache) GetAuthToken(key string) *Token {
 	if value, ok := tc.tokenMap.Load(key); ok {
-		return value.(*Token)
+		return value.(*cacheEntry).current
 	}
 	return nil
 }
 
-// SetAuthToken sets the authentication token in the cache.
-func (tc *Cache) SetAuthToken(key string, token *Token) {
-	if key == "" || token == nil {
-		return
+// GetPreviousAuthToken gets the authentication token replaced by the current one if it is still within the
+// overlap period, and the time until which it is kept.
+func (tc *Cache) GetPreviousAuthToken(key string) (*Token, time.Time) {
+	if value, ok := tc.tokenMap.Load(key); ok {
+		entry := value.(*cacheEntry)
+		if entry.previous != nil && entry.previousUntil.After(tc.clock.Now()) {
+			return entry.previous, entry.previousUntil
+		}
 	}
-	tc.tokenMap.Store(key, token)
+	return nil, time.Time{}
 }
 
-// RemoveAuthToken removes the authentication token from the cache.
-func (tc *Cache) RemoveAuthToken(key string) {
-	tc.tokenMap.Delete(key)
-}
-
-// Fallback gets the authentication token from the cache.
-func (tc *Cache) Fallback(request *model.GenerateAuthenticationTokenRequest) *Token {
-	inputFilePath := tc.generateInputFilePath(request)
-	if inputFilePath == "" {
-		return nil
+// SetAuthToken sets the authentication token in the cache. If it replaces a different token, the replaced
+// token is kept as the previous token for the overlap period, or until it expires if sooner.
+func (tc *Cache) SetAuthToken(key string, token *Token) {
+	if key == "" || token == nil {
+		return
 	}
 
-	// If the file exists, read the password from the file
-	if fileInfo, err := os.Stat(inputFilePath); err == nil {
-		logging.Infof("file name: %s, file size: %d", inputFilePath, fileInfo.Size())
-		// If the file size is 0 or the file size is greater than 200, skip the file
-		if fileInfo.Size() == 0 {
-			return nil
-		}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
 
-		if fileInfo.Size() > MaxPasswordSize {
-			logging.Errorf("The file size is greater than 200, skip the file: %s", inputFilePath)
-			return nil
-		}
-
-		lines, err := tc.readAllLines(inputFilePath)
-		if err != nil {
-			logging.Errorf("Failed to read all lines from the file: %s, error: %v", inputFilePath, err)
-			return nil
-		}
-
-		if len(lines) != 1 {
-			logging.Errorf("The file contains %d lines, expected exactly one line. Skipping file: %s",
-				len(lines), inputFilePath)
-			return nil
+	entry := &cacheEntry{current: token}
+	if value, ok := tc.tokenMap.Load(key); ok {
+		old := value.(*cacheEntry)
+		if old.current.GetAuthToken() != token.GetAuthToken() {
+			if tc.previousTokenOverlap > 0 {
+				// The replaced token is kept until the end of the overlap period, or until it expires if sooner.
+				entry.previous = old.current
+				entry.previousUntil = tc.clock.Now().Add(tc.previousTokenOverlap)
+				if expiresAt := old.current.GetExpiresAt(); expiresAt.Before(entry.previousUntil) {
+					entry.previousUntil = expiresAt
+				}
+			}
+		} else {
+			entry.previous, entry.previousUntil = old.previous, old.previousUntil
 		}
-
-		passwd := lines[0]
-
-		logging.Infof("Reading the password from the file: %s", inputFilePath)
-		return NewToken(passwd, utils.GetCurrentTimeMillis()+constants.MaxDelay)
 	}
-
-	return nil
+	tc.tokenMap.Store(key, entry)
 }
 
-func (tc *Cache) readAllLines(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %w", err)
-	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read file: %w", err)
-	}
+// RemoveAuthToken removes the authentication token and the previous token from the cache.
+func (tc *Cache) RemoveAuthToken(key string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
 
-	return lines, nil
+	tc.tokenMap.Delete(key)
 }
 
-func (tc *Cache) generateInputFilePath(request *model.GenerateAuthenticationTokenRequest) string {
-	region, instanceId, userName := request.Region(), request.InstanceId(), request.UserName()
-	wd, err := os.Getwd()
-	if err != nil {
-		logging.Errorf("Failed to get working directory: %v", err)
-		return ""
-	}
-	path := filepath.Join(constants.InputPathDir,
-		region+constants.DELIMITER+instanceId+constants.DELIMITER+userName+".pwd")
-	return filepath.Join(wd, path)
+// RemoveFallbackTokens removes every entry whose current token is a fallback token read from the source, and
+// returns how many were removed.
+func (tc *Cache) RemoveFallbackTokens(source string) int {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	removed := 0
+	tc.tokenMap.Range(func(key, value interface{}) bool {
+		if info := value.(*cacheEntry).current.GetFallbackInfo(); info != nil && info.Source == source {
+			tc.tokenMap.Delete(key)
+			removed++
+		}
+		return true
+	})
+	return removed
 }
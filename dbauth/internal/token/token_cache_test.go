@@ -0,0 +1,89 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+)
+
+func TestSetAuthToken_KeepsPreviousTokenDuringOverlap(t *testing.T) {
+	cache := NewTokenCache(time.Minute, clock.System)
+	expires := time.Now().Add(time.Minute)
+
+	cache.SetAuthToken("key", NewToken("old", expires))
+	cache.SetAuthToken("key", NewToken("new", expires))
+
+	assert.Equal(t, "new", cache.GetAuthToken("key").GetAuthToken())
+	previous, previousUntil := cache.GetPreviousAuthToken("key")
+	assert.Equal(t, "old", previous.GetAuthToken())
+	assert.True(t, previousUntil.After(time.Now()))
+}
+
+func TestSetAuthToken_SameTokenKeepsPrevious(t *testing.T) {
+	cache := NewTokenCache(time.Minute, clock.System)
+	expires := time.Now().Add(time.Minute)
+
+	cache.SetAuthToken("key", NewToken("old", expires))
+	cache.SetAuthToken("key", NewToken("new", expires))
+	cache.SetAuthToken("key", NewToken("new", expires.Add(time.Second)))
+
+	previous, _ := cache.GetPreviousAuthToken("key")
+	assert.Equal(t, "old", previous.GetAuthToken())
+}
+
+func TestSetAuthToken_NoOverlap(t *testing.T) {
+	cache := NewTokenCache(0, clock.System)
+	expires := time.Now().Add(time.Minute)
+
+	cache.SetAuthToken("key", NewToken("old", expires))
+	cache.SetAuthToken("key", NewToken("new", expires))
+
+	previous, _ := cache.GetPreviousAuthToken("key")
+	assert.Nil(t, previous)
+}
+
+func TestGetPreviousAuthToken_OverlapElapsed(t *testing.T) {
+	fakeClock := dbauthtest.NewFakeClock(time.Unix(1700000000, 0))
+	cache := NewTokenCache(50*time.Millisecond, fakeClock)
+	expires := fakeClock.Now().Add(time.Minute)
+
+	cache.SetAuthToken("key", NewToken("old", expires))
+	cache.SetAuthToken("key", NewToken("new", expires))
+	fakeClock.Advance(49 * time.Millisecond)
+	previous, _ := cache.GetPreviousAuthToken("key")
+	assert.NotNil(t, previous)
+
+	fakeClock.Advance(time.Millisecond)
+
+	previous, _ = cache.GetPreviousAuthToken("key")
+	assert.Nil(t, previous)
+}
+
+func TestRemoveAuthToken_RemovesBothTokens(t *testing.T) {
+	cache := NewTokenCache(time.Minute, clock.System)
+	expires := time.Now().Add(time.Minute)
+
+	cache.SetAuthToken("key", NewToken("old", expires))
+	cache.SetAuthToken("key", NewToken("new", expires))
+	cache.RemoveAuthToken("key")
+
+	assert.Nil(t, cache.GetAuthToken("key"))
+	previous, _ := cache.GetPreviousAuthToken("key")
+	assert.Nil(t, previous)
+}
+
+func TestRemoveFallbackTokens_MatchesSource(t *testing.T) {
+	cache := NewTokenCache(0, clock.System)
+	expires := time.Now().Add(time.Minute)
+	cache.SetAuthToken("first", NewFallbackToken("first", expires, &FallbackInfo{Source: "/input/first.pwd"}))
+	cache.SetAuthToken("second", NewFallbackToken("second", expires, &FallbackInfo{Source: "/input/second.pwd"}))
+	cache.SetAuthToken("cam", NewToken("cam", expires))
+
+	assert.Equal(t, 1, cache.RemoveFallbackTokens("/input/first.pwd"))
+	assert.Nil(t, cache.GetAuthToken("first"))
+	assert.Equal(t, "second", cache.GetAuthToken("second").GetAuthToken())
+	assert.Equal(t, "cam", cache.GetAuthToken("cam").GetAuthToken())
+}
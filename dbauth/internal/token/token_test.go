@@ -0,0 +1,18 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidFor(t *testing.T) {
+	now := time.Now()
+	authToken := NewToken("token", now.Add(time.Minute))
+
+	assert.True(t, authToken.IsValidFor(now, 0))
+	assert.True(t, authToken.IsValidFor(now, 59*time.Second))
+	assert.False(t, authToken.IsValidFor(now, time.Minute))
+	assert.False(t, authToken.IsValidFor(now.Add(2*time.Minute), 0))
+}
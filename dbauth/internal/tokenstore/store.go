@@ -0,0 +1,135 @@
+// Package tokenstore persists the tokens issued by CAM, so that they survive a restart of the process.
+package tokenstore
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/sealer"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/token"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+// fileSuffix is the suffix of the token files.
+const fileSuffix = ".token"
+
+// Store persists the tokens in a directory, one sealed file per auth key, named after a hash of the auth key.
+type Store struct {
+	dir string
+	key []byte
+}
+
+// persistedToken is the JSON plaintext of a token file.
+type persistedToken struct {
+	// AuthKey is checked when the token is loaded, so that a file renamed to the name of another auth key is
+	// not served for it.
+	AuthKey          string        `json:"authKey"`
+	Password         string        `json:"password"`
+	ExpiresAt        int64         `json:"expiresAt"`
+	RequestId        string        `json:"requestId,omitempty"`
+	CurrentTime      int64         `json:"currentTime,omitempty"`
+	NextRotationTime int64         `json:"nextRotationTime,omitempty"`
+	ClockSkew        time.Duration `json:"clockSkew,omitempty"`
+	TokenInfo        []byte        `json:"tokenInfo,omitempty"`
+}
+
+// NewStore creates a new Store which persists the tokens in the directory, sealed with the key.
+func NewStore(dir string, key []byte) *Store {
+	return &Store{dir: dir, key: key}
+}
+
+// Path returns the path of the token file of the auth key.
+func (s *Store) Path(authKey string) string {
+	sum := sha256.Sum256([]byte(authKey))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+fileSuffix)
+}
+
+// Save persists the token of the auth key, replacing the previous one. The directory is created if needed.
+func (s *Store) Save(authKey string, authToken *token.Token) error {
+	persisted := persistedToken{
+		AuthKey:   authKey,
+		Password:  authToken.GetAuthToken(),
+		ExpiresAt: authToken.GetExpires(),
+	}
+	if metadata := authToken.GetMetadata(); metadata != nil {
+		persisted.RequestId = metadata.RequestId
+		persisted.CurrentTime = metadata.CurrentTime
+		persisted.NextRotationTime = metadata.NextRotationTime
+		persisted.ClockSkew = metadata.ClockSkew
+		if metadata.TokenInfo != nil {
+			tokenInfo, err := proto.Marshal(metadata.TokenInfo)
+			if err != nil {
+				return fmt.Errorf("failed to marshal the token info: %w", err)
+			}
+			persisted.TokenInfo = tokenInfo
+		}
+	}
+	plaintext, err := json.Marshal(persisted)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create the token directory: %w", err)
+	}
+	return sealer.WriteFile(s.Path(authKey), s.key, plaintext)
+}
+
+// Load returns the persisted token of the auth key if it is still valid at now. It returns nil if there is no
+// token file, and removes the file of an expired token.
+func (s *Store) Load(authKey string, now time.Time) (*token.Token, error) {
+	path := s.Path(authKey)
+	sealed, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the token file: %w", err)
+	}
+	plaintext, err := sealer.Open(s.key, sealed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the token file %s: %w", path, err)
+	}
+
+	var persisted persistedToken
+	if err := json.Unmarshal(plaintext, &persisted); err != nil {
+		return nil, fmt.Errorf("failed to parse the token file %s: %w", path, err)
+	}
+	if persisted.AuthKey != authKey || persisted.Password == "" {
+		return nil, fmt.Errorf("the token file %s does not hold the token of the request", path)
+	}
+	expiresAt := time.Unix(0, persisted.ExpiresAt*int64(time.Millisecond))
+	if !expiresAt.After(now) {
+		return nil, s.Remove(authKey)
+	}
+
+	metadata := &token.Metadata{
+		RequestId:        persisted.RequestId,
+		CurrentTime:      persisted.CurrentTime,
+		NextRotationTime: persisted.NextRotationTime,
+		ClockSkew:        persisted.ClockSkew,
+	}
+	if persisted.TokenInfo != nil {
+		var tokenInfo pb.AuthTokenInfo
+		if err := proto.Unmarshal(persisted.TokenInfo, &tokenInfo); err != nil {
+			return nil, fmt.Errorf("failed to parse the token info of the token file %s: %w", path, err)
+		}
+		metadata.TokenInfo = &tokenInfo
+	}
+	return token.NewCamToken(persisted.Password, expiresAt, metadata), nil
+}
+
+// Remove removes the persisted token of the auth key, if any.
+func (s *Store) Remove(authKey string) error {
+	if err := os.Remove(s.Path(authKey)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove the token file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package tokenstore
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/sealer"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/token"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+func newTestStore(t *testing.T) *Store {
+	key, err := sealer.GenerateKey()
+	assert.NoError(t, err)
+	return NewStore(t.TempDir()+"/tokens", key)
+}
+
+func TestStore_SaveLoad(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+	expiresAt := now.Add(time.Hour)
+	assert.NoError(t, store.Save("authKey", token.NewCamToken("password", expiresAt, &token.Metadata{
+		RequestId:        "requestId",
+		CurrentTime:      1,
+		NextRotationTime: 2,
+		ClockSkew:        time.Second,
+		TokenInfo:        &pb.AuthTokenInfo{Password: "password", AppId: 42},
+	})))
+
+	fileInfo, err := os.Stat(store.Path("authKey"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fileInfo.Mode().Perm())
+
+	loaded, err := store.Load("authKey", now)
+	assert.NoError(t, err)
+	assert.Equal(t, "password", loaded.GetAuthToken())
+	assert.Equal(t, expiresAt.UnixNano()/int64(time.Millisecond), loaded.GetExpires())
+	assert.False(t, loaded.IsFallback())
+	assert.Equal(t, "requestId", loaded.GetMetadata().RequestId)
+	assert.Equal(t, int64(2), loaded.GetMetadata().NextRotationTime)
+	assert.Equal(t, time.Second, loaded.GetMetadata().ClockSkew)
+	assert.Equal(t, uint64(42), loaded.GetMetadata().TokenInfo.AppId)
+
+	loaded, err = store.Load("otherKey", now)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded)
+}
+
+func TestStore_LoadRemovesExpiredToken(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+	assert.NoError(t, store.Save("authKey", token.NewCamToken("password", now.Add(time.Minute), nil)))
+
+	loaded, err := store.Load("authKey", now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.Nil(t, loaded)
+	_, err = os.Stat(store.Path("authKey"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestStore_LoadRejectsForeignFiles(t *testing.T) {
+	store := newTestStore(t)
+	now := time.Now()
+	assert.NoError(t, store.Save("authKey", token.NewCamToken("password", now.Add(time.Hour), nil)))
+
+	// A file sealed with another key.
+	otherStore := NewStore(store.dir, make([]byte, sealer.KeySize))
+	_, err := otherStore.Load("authKey", now)
+	assert.Error(t, err)
+
+	// A file renamed to the name of another auth key.
+	assert.NoError(t, os.Rename(store.Path("authKey"), store.Path("otherKey")))
+	_, err = store.Load("otherKey", now)
+	assert.Error(t, err)
+
+	assert.NoError(t, store.Remove("otherKey"))
+	assert.NoError(t, store.Remove("otherKey"))
+}
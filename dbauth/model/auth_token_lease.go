@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// AuthTokenLease represents an authentication token and the time until which it is guaranteed to be valid.
+type AuthTokenLease struct {
+	// AuthToken is the authentication token used as the database password.
+	AuthToken string
+	// ValidUntil is the time the token expires. Connections should be established before then. It is in the past
+	// for a Stale token.
+	ValidUntil time.Time
+	// Fallback is whether the token is a fallback password rather than a token issued by CAM.
+	Fallback bool
+	// Stale is whether the token has expired and is still served under stale grace, because CAM failed to issue a
+	// new one with an error which does not require the attention of the user.
+	Stale bool
+}
+
+// AuthTokenPair represents the current authentication token and, during a rotation, the previous one.
+type AuthTokenPair struct {
+	// Current is the token new connections should try first.
+	Current *AuthTokenLease
+	// Previous is the token replaced by Current, nil outside of the overlap period or once it has expired. Its
+	// ValidUntil is the end of the overlap period, or its expiry if sooner. It can be tried when Current is denied
+	// because it has not propagated to the instance yet.
+	Previous *AuthTokenLease
+}
@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+// TokenSource identifies where a returned authentication token was served from.
+type TokenSource int
+
+const (
+	// TokenSourceCam means the token was just issued by CAM.
+	TokenSourceCam TokenSource = iota
+	// TokenSourceCache means the token was issued by CAM earlier and served from the cache.
+	TokenSourceCache
+	// TokenSourceFallback means the token is a fallback password, used because CAM could not issue a token.
+	TokenSourceFallback
+	// TokenSourceStaleGrace means the token has expired and is served from the cache because CAM failed.
+	TokenSourceStaleGrace
+)
+
+// String returns the name of the token source.
+func (s TokenSource) String() string {
+	switch s {
+	case TokenSourceCam:
+		return "CAM"
+	case TokenSourceCache:
+		return "Cache"
+	case TokenSourceFallback:
+		return "Fallback"
+	case TokenSourceStaleGrace:
+		return "StaleGrace"
+	default:
+		return "Unknown"
+	}
+}
+
+// AuthTokenInfo represents the metadata embedded in an authentication token issued by CAM.
+type AuthTokenInfo struct {
+	AppId      uint64
+	Uin        uint64
+	OwnerUin   uint64
+	CreateTime uint64
+	TokenType  uint32
+	ExtraInfo  string
+}
+
+// AuthTokenResult represents an authentication token together with its expiry and metadata.
+type AuthTokenResult struct {
+	// Password is the authentication token used as the database password.
+	Password string
+	// Expiry is the local time the token expires. It carries a monotonic clock reading, so time.Until
+	// is not affected by wall clock jumps. It is in the past for a token served under TokenSourceStaleGrace.
+	Expiry time.Time
+	// NextRotationTime is the time CAM rotates the token, zero if the token was not issued by CAM.
+	NextRotationTime time.Time
+	// CamCurrentTime is the CAM server time when the token was issued, zero if the token was not issued by CAM.
+	CamCurrentTime time.Time
+	// ClockSkew is the estimated offset of the CAM clock from the local clock when the token was issued,
+	// positive when the CAM clock is ahead. Zero if the token was not issued by CAM.
+	ClockSkew time.Duration
+	// RequestId is the id of the CAM request which issued the token, empty if the token was not issued by CAM.
+	RequestId string
+	// Source is where the token was served from.
+	Source TokenSource
+	// Info is the metadata embedded in the token, nil if the token was not issued by CAM.
+	Info *AuthTokenInfo
+	// Fallback is whether the token is a fallback password rather than a token issued by CAM, including when it
+	// is served under stale grace.
+	Fallback bool
+	// FallbackSource describes where the fallback password was read from, empty if the token is not a fallback
+	// password.
+	FallbackSource string
+	// FallbackMetadata is the metadata of the fallback password, nil if it has none.
+	FallbackMetadata map[string]string
+}
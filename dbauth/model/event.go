@@ -0,0 +1,84 @@
+package model
+
+import "time"
+
+// CircuitState is the state of the circuit breaker of a CAM endpoint.
+type CircuitState int
+
+const (
+	// CircuitClosed sends the CAM requests to the endpoint.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen sends no CAM request to the endpoint, so that the tokens are served from the cache, under stale
+	// grace or from the fallback.
+	CircuitOpen
+	// CircuitHalfOpen sends a single probe request to the endpoint, which closes the circuit if it succeeds and
+	// opens it again otherwise.
+	CircuitHalfOpen
+)
+
+// String returns the name of the circuit state.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "Closed"
+	case CircuitOpen:
+		return "Open"
+	case CircuitHalfOpen:
+		return "HalfOpen"
+	default:
+		return "Unknown"
+	}
+}
+
+// EventType is the type of an Event.
+type EventType int
+
+const (
+	// EventCircuitStateChanged is emitted when the circuit breaker of a CAM endpoint changes state.
+	EventCircuitStateChanged EventType = iota
+	// EventFallbackChanged is emitted when a fallback password changes, which drops the cached fallback tokens.
+	EventFallbackChanged
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventCircuitStateChanged:
+		return "CircuitStateChanged"
+	case EventFallbackChanged:
+		return "FallbackChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event is a notable change of the state of a client.
+type Event struct {
+	// Type is the type of the event.
+	Type EventType
+	// Time is the time the event happened.
+	Time time.Time
+	// Endpoint is the CAM endpoint the event is about, if any.
+	Endpoint string
+	// CircuitState is the new state of the circuit breaker of an EventCircuitStateChanged event.
+	CircuitState CircuitState
+	// PreviousCircuitState is the former state of the circuit breaker of an EventCircuitStateChanged event.
+	PreviousCircuitState CircuitState
+	// Source is the source of the changed password of an EventFallbackChanged event, such as a file path.
+	Source string
+}
+
+// EventListener receives the events of a client. OnEvent is called synchronously from the goroutines of the token
+// requests and of the background refreshes, so it must be safe for concurrent use and should not block.
+type EventListener interface {
+	// OnEvent handles the event.
+	OnEvent(event Event)
+}
+
+// EventListenerFunc is a function used as an EventListener.
+type EventListenerFunc func(event Event)
+
+// OnEvent calls f(event).
+func (f EventListenerFunc) OnEvent(event Event) {
+	f(event)
+}
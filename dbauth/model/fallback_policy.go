@@ -0,0 +1,61 @@
+package model
+
+import (
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/errorcode"
+)
+
+// FallbackPolicy decides whether the fallback password of a token request is served after CAM failed to issue a
+// token. The built-in policies are the FallbackMode values.
+type FallbackPolicy interface {
+	// AllowFallback reports whether the fallback password of the request may be served after CAM failed with err.
+	AllowFallback(request *GenerateAuthenticationTokenRequest, err error) bool
+}
+
+// FallbackPolicyFunc is a function used as a FallbackPolicy.
+type FallbackPolicyFunc func(request *GenerateAuthenticationTokenRequest, err error) bool
+
+// AllowFallback calls f(request, err).
+func (f FallbackPolicyFunc) AllowFallback(request *GenerateAuthenticationTokenRequest, err error) bool {
+	return f(request, err)
+}
+
+// FallbackMode is a built-in FallbackPolicy.
+type FallbackMode int
+
+const (
+	// FallbackOnRetryableErrors serves the fallback password unless CAM answered an error which requires the
+	// attention of the user, such as an AuthFailure error or a disabled CAM authentication of the instance
+	// (ResourceNotFound.DataFlowAuthClose). It is the default policy.
+	FallbackOnRetryableErrors FallbackMode = iota
+	// FallbackNever never serves the fallback password.
+	FallbackNever
+	// FallbackAlways serves the fallback password whatever the error, such as during a planned disablement of the
+	// CAM authentication of the instance.
+	FallbackAlways
+)
+
+// String returns the name of the fallback mode.
+func (m FallbackMode) String() string {
+	switch m {
+	case FallbackOnRetryableErrors:
+		return "OnRetryableErrors"
+	case FallbackNever:
+		return "Never"
+	case FallbackAlways:
+		return "Always"
+	default:
+		return "Unknown"
+	}
+}
+
+// AllowFallback reports whether the fallback mode serves the fallback password after CAM failed with err.
+func (m FallbackMode) AllowFallback(_ *GenerateAuthenticationTokenRequest, err error) bool {
+	switch m {
+	case FallbackOnRetryableErrors:
+		return !errorcode.IsUserNotificationRequired(err)
+	case FallbackAlways:
+		return true
+	default:
+		return false
+	}
+}
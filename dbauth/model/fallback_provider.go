@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/clock"
+)
+
+// FallbackPassword is a password provided by a FallbackProvider, which is served when CAM cannot issue a token.
+type FallbackPassword struct {
+	// Value is the password.
+	Value string
+	// Expiry is the time until which the password is served, 24 hours after it was provided if zero.
+	Expiry time.Time
+	// Source describes where the password was read from, such as a file path, for the logs. It must not contain
+	// the password.
+	Source string
+	// Metadata is free-form information about the password, such as the metadata of an encrypted password file.
+	Metadata map[string]string
+}
+
+// FallbackProvider provides the password of a token request when CAM cannot issue a token, such as a password
+// read from a file or a secret store. The package fallback contains the built-in providers.
+type FallbackProvider interface {
+	// FallbackPassword returns the password of the token request, or nil if the provider has none.
+	FallbackPassword(request *GenerateAuthenticationTokenRequest) (*FallbackPassword, error)
+}
+
+// FallbackWatcher is implemented by the fallback providers which detect the changes of their passwords, such as a
+// fallback.DirectoryProvider polling its directory. A client drops the cached fallback tokens of a password when
+// it changes, so that the new password is served.
+type FallbackWatcher interface {
+	// WatchFallback checks the passwords for changes at the interval, as measured by the clock, and calls onChange
+	// with the source of every changed password, such as a file path. The source is the FallbackPassword.Source
+	// of the password. It returns the function which stops it.
+	WatchFallback(interval time.Duration, watchClock clock.Clock, onChange func(source string)) (stop func())
+}
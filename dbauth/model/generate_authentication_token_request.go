@@ -2,6 +2,8 @@
 package model
 
 import (
+	"time"
+
 	errorcodes "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/cam/v20190116"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
 	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/errors"
@@ -10,11 +12,14 @@ import (
 
 // GenerateAuthenticationTokenRequest represents the request to generate an authentication token.
 type GenerateAuthenticationTokenRequest struct {
-	region        string
-	instanceId    string
-	userName      string
-	credential    *common.Credential
-	clientProfile *profile.ClientProfile
+	region         string
+	instanceId     string
+	userName       string
+	credential     *common.Credential
+	clientProfile  *profile.ClientProfile
+	hot            bool
+	minValidity    time.Duration
+	fallbackPolicy FallbackPolicy
 }
 
 // NewGenerateAuthenticationTokenRequest creates a new GenerateAuthenticationTokenRequest.
@@ -71,3 +76,36 @@ func (r *GenerateAuthenticationTokenRequest) Credential() *common.Credential {
 func (r *GenerateAuthenticationTokenRequest) ClientProfile() *profile.ClientProfile {
 	return r.clientProfile
 }
+
+// IsHot returns whether the token of this request is refreshed in the background in RefreshModeHybrid.
+func (r *GenerateAuthenticationTokenRequest) IsHot() bool {
+	return r.hot
+}
+
+// SetHot marks the token of this request as hot, so that it is refreshed in the background in RefreshModeHybrid.
+func (r *GenerateAuthenticationTokenRequest) SetHot(hot bool) {
+	r.hot = hot
+}
+
+// MinValidity returns the minimum remaining lifetime of the returned token, zero if the client default applies.
+func (r *GenerateAuthenticationTokenRequest) MinValidity() time.Duration {
+	return r.minValidity
+}
+
+// SetMinValidity sets the minimum remaining lifetime of the returned token, overriding the client default.
+// A cached token that expires sooner is refreshed synchronously first. It is clamped to half of the lifetime of
+// the token.
+func (r *GenerateAuthenticationTokenRequest) SetMinValidity(minValidity time.Duration) {
+	r.minValidity = minValidity
+}
+
+// FallbackPolicy returns the fallback policy of the request, nil if the client policy applies.
+func (r *GenerateAuthenticationTokenRequest) FallbackPolicy() FallbackPolicy {
+	return r.fallbackPolicy
+}
+
+// SetFallbackPolicy sets the policy deciding whether the fallback password of the request is served after CAM
+// failed to issue a token, overriding the client policy. It also applies to the background refreshes of the token.
+func (r *GenerateAuthenticationTokenRequest) SetFallbackPolicy(fallbackPolicy FallbackPolicy) {
+	r.fallbackPolicy = fallbackPolicy
+}
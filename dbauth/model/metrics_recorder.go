@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+const (
+	// MetricCamQueueDelay is the duration a CAM request waited for the rate limiter and the concurrency limit.
+	MetricCamQueueDelay = "dbauth_cam_queue_delay"
+	// MetricCamThrottled counts the CAM requests rejected with RequestLimitExceeded.
+	MetricCamThrottled = "dbauth_cam_throttled"
+	// MetricCamRateLimit is the current rate limit, in requests per second, after the adaptive adjustments.
+	MetricCamRateLimit = "dbauth_cam_rate_limit"
+	// MetricCamCircuitState is the state of the circuit breaker of a CAM endpoint, as the value of its
+	// CircuitState, labelled with the endpoint.
+	MetricCamCircuitState = "dbauth_cam_circuit_state"
+	// MetricCamCircuitTransitions counts the state changes of the circuit breaker of a CAM endpoint, labelled with
+	// the endpoint and the new state.
+	MetricCamCircuitTransitions = "dbauth_cam_circuit_transitions"
+)
+
+// MetricsRecorder records the metrics of a client, such as a Prometheus or StatsD adapter. Its methods are called
+// from the goroutines of the token requests and of the background refreshes, so they must be safe for concurrent
+// use and should not block.
+type MetricsRecorder interface {
+	// IncCounter increments the counter with the provided name and labels.
+	IncCounter(name string, labels map[string]string)
+	// SetGauge sets the gauge with the provided name and labels to the value.
+	SetGauge(name string, value float64, labels map[string]string)
+	// ObserveDuration records a duration in the histogram with the provided name and labels.
+	ObserveDuration(name string, duration time.Duration, labels map[string]string)
+}
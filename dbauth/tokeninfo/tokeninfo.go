@@ -0,0 +1,73 @@
+// Package tokeninfo inspects the authentication tokens issued by CAM offline.
+//
+// The raw token returned by CAM BuildDataFlowAuthToken is an integrity hash followed by the encrypted
+// token information. Parse verifies and decrypts it with the instance, region and user the token was issued for.
+package tokeninfo
+
+import (
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/internal/parser"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+// The errors returned by Parse wrap one of these errors, they can be checked with errors.Is.
+var (
+	ErrInvalidParam         = parser.ErrInvalidParam
+	ErrMalformedToken       = parser.ErrMalformedToken
+	ErrDecryptionFailed     = parser.ErrDecryptionFailed
+	ErrIntegrityCheckFailed = parser.ErrIntegrityCheckFailed
+	ErrIdentityMismatch     = parser.ErrIdentityMismatch
+	// ErrUnsupportedTokenFormat matches the UnsupportedTokenFormatError returned for unknown token formats.
+	ErrUnsupportedTokenFormat = parser.ErrUnsupportedTokenFormat
+)
+
+// UnsupportedTokenFormatError is returned when the envelope version or the token type of a token is unknown.
+type UnsupportedTokenFormatError = parser.UnsupportedTokenFormatError
+
+// Info represents the information embedded in an authentication token. It mirrors pb.AuthTokenInfo.
+type Info struct {
+	AppId      uint64 `json:"appId"`
+	Uin        uint64 `json:"uin"`
+	OwnerUin   uint64 `json:"ownerUin"`
+	ReqId      string `json:"reqId"`
+	InstanceId string `json:"instanceId"`
+	Region     string `json:"region"`
+	Username   string `json:"username"`
+	// Password is the database password carried by the token. It is never marshaled to JSON,
+	// so that an Info can be logged safely.
+	Password   string `json:"-"`
+	CreateTime uint64 `json:"createTime"`
+	ExtraInfo  string `json:"extraInfo"`
+	TokenType  uint32 `json:"tokenType"`
+	RandNum    uint32 `json:"randNum"`
+}
+
+// Parse verifies the integrity of the raw token issued by CAM for the instance, region and user,
+// decrypts it and returns the information it carries.
+func Parse(instanceId, region, userName, token string) (*Info, error) {
+	tokenInfo, err := parser.ParseAuthToken(instanceId, region, userName, token)
+	if err != nil {
+		return nil, err
+	}
+	return FromProto(tokenInfo), nil
+}
+
+// FromProto converts the protobuf token information into an Info.
+func FromProto(tokenInfo *pb.AuthTokenInfo) *Info {
+	if tokenInfo == nil {
+		return nil
+	}
+	return &Info{
+		AppId:      tokenInfo.AppId,
+		Uin:        tokenInfo.Uin,
+		OwnerUin:   tokenInfo.OwnerUin,
+		ReqId:      tokenInfo.ReqId,
+		InstanceId: tokenInfo.InstanceId,
+		Region:     tokenInfo.Region,
+		Username:   tokenInfo.Username,
+		Password:   tokenInfo.Password,
+		CreateTime: tokenInfo.CreateTime,
+		ExtraInfo:  tokenInfo.ExtraInfo,
+		TokenType:  tokenInfo.TokenType,
+		RandNum:    tokenInfo.RandNum,
+	}
+}
@@ -0,0 +1,62 @@
+package tokeninfo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/dbauthtest"
+	"github.com/tencentcloud/dbauth-sdk-go/dbauth/pb"
+)
+
+func newTestToken(t *testing.T) string {
+	token, err := dbauthtest.NewAuthToken("cdb-123456", "ap-guangzhou", "camtest", &pb.AuthTokenInfo{
+		AppId:      1250000000,
+		Uin:        100000000001,
+		OwnerUin:   100000000000,
+		InstanceId: "cdb-123456",
+		Region:     "ap-guangzhou",
+		Username:   "camtest",
+		Password:   "secret",
+		CreateTime: 1700000000000,
+		TokenType:  1,
+	})
+	assert.NoError(t, err)
+	return token
+}
+
+func TestParse_ValidToken(t *testing.T) {
+	info, err := Parse("cdb-123456", "ap-guangzhou", "camtest", newTestToken(t))
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1250000000), info.AppId)
+	assert.Equal(t, uint64(100000000001), info.Uin)
+	assert.Equal(t, "cdb-123456", info.InstanceId)
+	assert.Equal(t, "secret", info.Password)
+	assert.Equal(t, uint32(1), info.TokenType)
+}
+
+func TestParse_WrongIdentity(t *testing.T) {
+	_, err := Parse("cdb-654321", "ap-guangzhou", "camtest", newTestToken(t))
+	assert.Error(t, err)
+}
+
+func TestParse_TamperedIntegrityPrefix(t *testing.T) {
+	token := newTestToken(t)
+	tampered := "0" + token[1:]
+	if token[0] == '0' {
+		tampered = "1" + token[1:]
+	}
+	_, err := Parse("cdb-123456", "ap-guangzhou", "camtest", tampered)
+	assert.Error(t, err)
+}
+
+func TestInfo_MarshalJSONOmitsPassword(t *testing.T) {
+	info, err := Parse("cdb-123456", "ap-guangzhou", "camtest", newTestToken(t))
+	assert.NoError(t, err)
+
+	data, err := json.Marshal(info)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), `"appId":1250000000`)
+}